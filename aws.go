@@ -0,0 +1,164 @@
+package weplus
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/comprehend"
+	"github.com/aws/aws-sdk-go-v2/service/comprehend/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+type s3Store struct {
+	ctx    context.Context
+	client *s3.Client
+	bucket string
+}
+
+func (s *s3Store) download(file string) ([]byte, error) {
+	raw, _, err := s.downloadVersion(file)
+	return raw, err
+}
+
+// downloadVersion returns the file and its etag.
+func (s *s3Store) downloadVersion(file string) ([]byte, string, error) {
+	resp, err := s.client.GetObject(s.ctx, &s3.GetObjectInput{Bucket: &s.bucket, Key: &file})
+	if err != nil {
+		if strings.Contains(err.Error(), "NoSuchKey") {
+			return nil, "", fmt.Errorf("couldn't find s3://%s/%s. %w", s.bucket, file, errNotFound)
+		}
+		return nil, "", fmt.Errorf("couldn't download file from s3://%s/%s. %w", s.bucket, file, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("couldn't read body of file from s3://%s/%s. %w", s.bucket, file, err)
+	}
+
+	etag := ""
+	if resp.ETag != nil {
+		etag = *resp.ETag
+	}
+
+	return raw, etag, nil
+}
+
+// saveIf makes a conditional put. The sdk doesn't have fields for the conditions so
+// the headers are set on the request.
+func (s *s3Store) saveIf(file string, raw []byte, version string) (string, error) {
+	header, value := "If-Match", version
+	if version == "" {
+		header, value = "If-None-Match", "*"
+	}
+
+	resp, err := s.client.PutObject(s.ctx, &s3.PutObjectInput{
+		Bucket: &s.bucket,
+		Key:    &file,
+		Body:   bytes.NewReader(raw),
+	}, func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, smithyhttp.SetHeaderValue(header, value))
+	})
+	if err != nil {
+		// A failed condition is 412 and a concurrent conditional put 409.
+		var respErr *smithyhttp.ResponseError
+		if errors.As(err, &respErr) && (respErr.HTTPStatusCode() == http.StatusPreconditionFailed || respErr.HTTPStatusCode() == http.StatusConflict) {
+			return "", fmt.Errorf("couldn't save file to s3://%s/%s. %w", s.bucket, file, errConflict)
+		}
+		return "", fmt.Errorf("couldn't save file to s3://%s/%s. %w", s.bucket, file, err)
+	}
+
+	etag := ""
+	if resp.ETag != nil {
+		etag = *resp.ETag
+	}
+
+	return etag, nil
+}
+
+func (s *s3Store) remove(file string) error {
+	if _, err := s.client.DeleteObject(s.ctx, &s3.DeleteObjectInput{Bucket: &s.bucket, Key: &file}); err != nil {
+		return fmt.Errorf("couldn't remove file s3://%s/%s. %w", s.bucket, file, err)
+	}
+
+	return nil
+}
+
+type kmsSecrets struct {
+	ctx    context.Context
+	client *kms.Client
+}
+
+func (k *kmsSecrets) getPassword(email string) (string, error) {
+	str := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(email, ".", "_"), "-", "_"), "@", "_"))
+
+	raw := os.Getenv(str)
+	if raw == "" {
+		return "", fmt.Errorf("couldn't read env var %s", str)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return "", fmt.Errorf("couldn't base64 decode password for %s", str)
+	}
+
+	res, err := k.client.Decrypt(k.ctx, &kms.DecryptInput{CiphertextBlob: decoded})
+	if err != nil {
+		return "", fmt.Errorf("couldn't decrypt password %s. %w", str, err)
+	}
+
+	return string(res.Plaintext), nil
+}
+
+type comprehendAnalyzer struct {
+	ctx    context.Context
+	client *comprehend.Client
+}
+
+func (c *comprehendAnalyzer) sentiment(text string) (types.SentimentType, error) {
+	lang, err := c.client.DetectDominantLanguage(c.ctx, &comprehend.DetectDominantLanguageInput{
+		Text: &text,
+	})
+	if err != nil {
+		return "", fmt.Errorf("couldn't detect language for %s. %w", text, err)
+	}
+
+	langCode := language(lang.Languages, supportedLanguages)
+
+	res, err := c.client.DetectSentiment(c.ctx, &comprehend.DetectSentimentInput{
+		Text:         &text,
+		LanguageCode: langCode,
+	})
+	if err != nil {
+		return "", fmt.Errorf("couldn't get sentiment of text %s. %w", text, err)
+	}
+
+	return res.Sentiment, nil
+}
+
+var supportedLanguages = []string{"de", "en", "es", "it", "pt", "fr", "ja", "ko", "hi", "ar", "zh", "zh-TW"}
+
+func language(languages []types.DominantLanguage, supported []string) types.LanguageCode {
+	lang, top := "en", float32(0)
+	for _, detectedLang := range languages {
+		if *detectedLang.Score > top {
+			lang, top = *detectedLang.LanguageCode, *detectedLang.Score
+		}
+	}
+
+	for _, supportedLang := range supported {
+		if lang == supportedLang {
+			return types.LanguageCode(lang)
+		}
+	}
+
+	return types.LanguageCode("en")
+}
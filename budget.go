@@ -0,0 +1,98 @@
+package weplus
+
+import (
+	"time"
+)
+
+// budget is how many more posts can be liked and commented in the run. A negative
+// value is unlimited.
+type budget struct {
+	likes    int
+	comments int
+}
+
+// newBudget returns what's left of the per run and daily maximums in inp.
+func newBudget(inp *Input, data *data, now time.Time) *budget {
+	day := now.Add(-24 * time.Hour)
+	return &budget{
+		likes:    remaining(inp.MaxLikes, inp.MaxDailyLikes, since(data.Liked, day)),
+		comments: remaining(inp.MaxComments, inp.MaxDailyComments, since(data.Commented, day)),
+	}
+}
+
+// remaining returns the smallest of the per run maximum and what's left of the daily
+// maximum, or -1 if neither is set.
+func remaining(perRun *int, daily *int, today int) int {
+	n := -1
+	if perRun != nil {
+		n = *perRun
+	}
+	if daily != nil {
+		left := *daily - today
+		if left < 0 {
+			left = 0
+		}
+		if n < 0 || left < n {
+			n = left
+		}
+	}
+
+	return n
+}
+
+// fits returns if there is budget left for what was decided for the post.
+func (b *budget) fits(act *action) bool {
+	if b == nil {
+		return true
+	}
+
+	return (!act.Like || b.likes != 0) && (len(act.Comments) == 0 || b.comments != 0)
+}
+
+// use removes what was decided for the post from the budget.
+func (b *budget) use(act *action) {
+	if b == nil {
+		return
+	}
+
+	if act.Like && b.likes > 0 {
+		b.likes--
+	}
+	if len(act.Comments) > 0 && b.comments > 0 {
+		b.comments--
+	}
+}
+
+// since returns the number of dates after date.
+func since(dates []time.Time, date time.Time) int {
+	n := 0
+	for _, d := range dates {
+		if d.After(date) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// after returns the dates after date.
+func after(dates []time.Time, date time.Time) []time.Time {
+	res := []time.Time{}
+	for _, d := range dates {
+		if d.After(date) {
+			res = append(res, d)
+		}
+	}
+
+	return res
+}
+
+// done records when the post was liked and commented, if there are daily maximums.
+func (d *data) done(act *action, inp *Input, now time.Time) {
+	if act.Liked && inp.MaxDailyLikes != nil {
+		d.Liked = append(d.Liked, now)
+	}
+	if act.Commented && inp.MaxDailyComments != nil {
+		d.Commented = append(d.Commented, now)
+	}
+}
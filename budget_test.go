@@ -0,0 +1,85 @@
+package weplus
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRemaining(t *testing.T) {
+	zero, one, three := 0, 1, 3
+
+	cases := []struct {
+		name   string
+		perRun *int
+		daily  *int
+		today  int
+		want   int
+	}{
+		{name: "unlimited", want: -1},
+		{name: "per run", perRun: &three, want: 3},
+		{name: "daily", daily: &three, today: 1, want: 2},
+		{name: "lowest wins", perRun: &one, daily: &three, want: 1},
+		{name: "daily used up", perRun: &three, daily: &one, today: 2, want: 0},
+		{name: "none per run", perRun: &zero, daily: &three, want: 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := remaining(c.perRun, c.daily, c.today); got != c.want {
+				t.Errorf("expected %d but got %d", c.want, got)
+			}
+		})
+	}
+}
+
+func TestRunBudget(t *testing.T) {
+	fw := newFakeWeplus(t)
+	fw.add("group",
+		&fakePost{ID: "2000001", UserID: "10002", Name: "Cecilia Carlsson", Group: "@Hawks", Duration: 30, Kind: "Yoga", Date: date(t, "Sat, 20 Mar 2021 06:00:00 +0100")},
+		&fakePost{ID: "2000002", UserID: "10003", Name: "David Dahl", Group: "@Hawks", Duration: 45, Kind: "Löpning", Date: date(t, "Sat, 20 Mar 2021 07:00:00 +0100")},
+		&fakePost{ID: "2000003", UserID: "10004", Name: "Frida Falk", Group: "@Hawks", Duration: 60, Kind: "Cykling", Date: date(t, "Sat, 20 Mar 2021 08:00:00 +0100")},
+	)
+
+	store := fw.newStore("| type == group | Nice")
+
+	one, two := 1, 2
+	run := func(t *testing.T) *Report {
+		return fw.run(t, store, &Input{Email: fw.email, MaxComments: &one, MaxDailyLikes: &two})
+	}
+
+	// One post is commented per run and the rest are deferred but still seen.
+	report := run(t)
+	if report.Counts.Commented != 1 || report.Counts.Deferred != 2 {
+		t.Fatalf("expected 1 commented and 2 deferred but got %+v", report.Counts)
+	}
+
+	raw, _ := store.download("erik@example.com.json")
+	d := &data{}
+	if err := json.Unmarshal(raw, d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Group) != 3 || len(d.Pending) != 2 || len(d.Liked) != 1 || len(d.Commented) != 0 {
+		t.Fatalf("expected 3 seen, 2 pending and 1 like in the state but got %s", raw)
+	}
+
+	// The deferred posts are done oldest first in the next runs.
+	report = run(t)
+	if report.Counts.Commented != 1 || report.Counts.Deferred != 1 || !report.Actions[0].Resumed {
+		t.Fatalf("expected 1 resumed comment and 1 deferred but got %+v", report.Counts)
+	}
+
+	// The daily likes are used up so the last one stays deferred.
+	report = run(t)
+	if report.Counts.Liked != 0 || report.Counts.Deferred != 1 {
+		t.Fatalf("expected the last post to stay deferred but got %+v", report.Counts)
+	}
+
+	want := []string{"2000003", "2000002"}
+	if got := fw.liked(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected likes %q but got %q", want, got)
+	}
+	if got := fw.commented(); len(got) != 2 {
+		t.Errorf("expected 2 comments but got %d", len(got))
+	}
+}
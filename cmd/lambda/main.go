@@ -0,0 +1,10 @@
+package main
+
+import (
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/nuttmeister/weplus"
+)
+
+func main() {
+	lambda.Start(weplus.Handler)
+}
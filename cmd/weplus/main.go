@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nuttmeister/weplus"
+)
+
+type options struct {
+	inp      *weplus.Input
+	password string
+	stateDir string
+	once     bool
+	every    time.Duration
+	timeout  time.Duration
+}
+
+func main() {
+	opts, err := input()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if opts.once {
+		if err := run(ctx, opts); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	ticker := time.NewTicker(opts.every)
+	defer ticker.Stop()
+
+	for {
+		// Keep running on errors, the next tick might succeed.
+		if err := run(ctx, opts); err != nil {
+			log.Print(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func run(ctx context.Context, opts *options) error {
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+
+	report, err := weplus.RunLocal(ctx, opts.inp, opts.stateDir, opts.password)
+	if err != nil {
+		return err
+	}
+
+	// Mark as seen only makes sense for the first run.
+	opts.inp.MarkAsSeen = false
+
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(raw))
+	return nil
+}
+
+func input() (*options, error) {
+	email := flag.String("email", "", "the email to log in with [*required]")
+	password := flag.String("password", "", "the password to log in with. defaults to env WEPLUS_PASSWORD")
+	passwordFile := flag.String("password-file", "", "file to read the password from instead of --password")
+	stateDir := flag.String("state-dir", "state", "directory with <email>.comments.{yaml,yml,json,txt} and where <email>.json state is saved")
+	markAsSeen := flag.Bool("mark-as-seen", false, "mark all current posts as seen without liking or commenting")
+	dryRun := flag.Bool("dry-run", false, "print what would be liked and commented without doing it or saving state")
+	likeRatio := flag.Float64("like-ratio", 1.0, "ratio of company posts to like")
+	commentRatio := flag.Float64("comment-ratio", 0.8, "ratio of company posts to comment")
+	groupLikeRatio := flag.Float64("group-like-ratio", 1.0, "ratio of group posts to like")
+	groupCommentRatio := flag.Float64("group-comment-ratio", 1.0, "ratio of group posts to comment")
+	commentWindowDays := flag.Int("comment-window-days", 7, "days before the same comment is made to the same person again. 0 disables it")
+	maxLikes := flag.Int("max-likes", 0, "max posts to like per run, the rest are deferred to the next run")
+	maxComments := flag.Int("max-comments", 0, "max posts to comment per run, the rest are deferred to the next run")
+	maxDailyLikes := flag.Int("max-daily-likes", 0, "max posts to like in the last 24 hours")
+	maxDailyComments := flag.Int("max-daily-comments", 0, "max posts to comment in the last 24 hours")
+	spreadMinutes := flag.Int("spread-minutes", 0, "spread the actions of a run over this many minutes, later runs send them when due")
+	minAgeMinutes := flag.Int("min-age-minutes", 0, "minutes old a post is before it's liked or commented")
+	activeHours := flag.String("active-hours", "", "hours to send actions in, such as 07:00-21:00")
+	seenDays := flag.Int("seen-days", 90, "days a seen post is remembered in the state")
+	seed := flag.Int64("seed", 0, "seed for the random choices, use the seed in the report of an earlier run to replay it")
+	timezone := flag.String("timezone", "", "timezone for time based comment rules, such as Europe/Stockholm")
+	once := flag.Bool("once", false, "run once and exit")
+	every := flag.Duration("every", 0, "run every interval, such as 1h, until interrupted")
+	timeout := flag.Duration("timeout", 15*time.Minute, "max duration of a single run")
+	flag.Parse()
+
+	opts := &options{
+		inp: &weplus.Input{
+			Email:             *email,
+			LikeRatio:         likeRatio,
+			CommentRatio:      commentRatio,
+			GroupLikeRatio:    groupLikeRatio,
+			GroupCommentRatio: groupCommentRatio,
+			CommentWindowDays: commentWindowDays,
+			SeenDays:          seenDays,
+			MarkAsSeen:        *markAsSeen,
+			DryRun:            *dryRun,
+			Timezone:          *timezone,
+			ActiveHours:       *activeHours,
+		},
+		password: *password,
+		stateDir: *stateDir,
+		once:     *once || *every == 0,
+		every:    *every,
+		timeout:  *timeout,
+	}
+
+	// Only replay a run or limit it if the flag was set, 0 is a valid value.
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "seed":
+			opts.inp.Seed = seed
+		case "max-likes":
+			opts.inp.MaxLikes = maxLikes
+		case "max-comments":
+			opts.inp.MaxComments = maxComments
+		case "max-daily-likes":
+			opts.inp.MaxDailyLikes = maxDailyLikes
+		case "max-daily-comments":
+			opts.inp.MaxDailyComments = maxDailyComments
+		case "spread-minutes":
+			opts.inp.SpreadMinutes = spreadMinutes
+		case "min-age-minutes":
+			opts.inp.MinAgeMinutes = minAgeMinutes
+		}
+	})
+
+	if *passwordFile != "" {
+		raw, err := os.ReadFile(*passwordFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.password = strings.TrimRight(string(raw), "\r\n")
+	}
+	if opts.password == "" {
+		opts.password = os.Getenv("WEPLUS_PASSWORD")
+	}
+
+	switch {
+	case *email == "":
+		return nil, fmt.Errorf("input email is required")
+	case opts.password == "":
+		return nil, fmt.Errorf("a password is required, use --password, --password-file or env WEPLUS_PASSWORD")
+	case *once && *every != 0:
+		return nil, fmt.Errorf("--once and --every can't be combined")
+	case *every < 0:
+		return nil, fmt.Errorf("--every must be positive")
+	}
+
+	return opts, nil
+}
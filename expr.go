@@ -0,0 +1,559 @@
+package weplus
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/comprehend/types"
+)
+
+// The operands supported by each key. Longer operands must come first so they are matched before their prefixes.
+var (
+	operands    = []string{"!=~", "=~", "==", "!=", "<=", ">=", "!~", "<", ">", "~"}
+	keyOperands = map[string][]string{
+		"name":      {"==", "!=", "~", "!~", "=~", "!=~"},
+		"group":     {"==", "!=", "~", "!~", "=~", "!=~"},
+		"type":      {"==", "!=", "~", "!~", "=~", "!=~"},
+		"duration":  {"==", "<=", ">=", "<", ">"},
+		"time":      {"==", "<=", ">=", "<", ">"},
+		"sentiment": {"==", "!="},
+		"weekday":   {"==", "!="},
+		"month":     {"==", "!="},
+		"date":      {"==", "!=", "<=", ">=", "<", ">"},
+		"age":       {"==", "<=", ">=", "<", ">"},
+	}
+
+	// The keys that are about when the post was made, they are also evaluated on group and none exercise posts.
+	dateKeys = map[string]bool{"time": true, "weekday": true, "month": true, "date": true, "age": true}
+)
+
+const (
+	dayFormat        = "2006-01-02"
+	yearlyDateFormat = "01-02"
+	rangeSeparator   = ".."
+)
+
+// expr is a parsed comment rule expression that can be evaluated against a post.
+type expr interface {
+	eval(env *env) bool
+	has(fn func(e *expression) bool) bool
+	String() string
+}
+
+// env is what an expression is evaluated against. Dates are compared in loc.
+type env struct {
+	post *post
+	loc  *time.Location
+	now  time.Time
+}
+
+type andExpr struct {
+	left  expr
+	right expr
+}
+
+type orExpr struct {
+	left  expr
+	right expr
+}
+
+type notExpr struct {
+	expr expr
+}
+
+// expression is a single "key operand value" comparison. number is the value as a
+// number, such as minutes for duration, time and age, and to the end of a range for
+// weekday, month and date. re is the compiled value for =~ and !=~.
+type expression struct {
+	key     string
+	operand string
+	value   string
+	number  int
+	to      int
+	yearly  bool
+	re      *regexp.Regexp
+}
+
+func (e *andExpr) eval(env *env) bool { return e.left.eval(env) && e.right.eval(env) }
+func (e *orExpr) eval(env *env) bool  { return e.left.eval(env) || e.right.eval(env) }
+func (e *notExpr) eval(env *env) bool { return !e.expr.eval(env) }
+
+func (e *andExpr) has(fn func(e *expression) bool) bool { return e.left.has(fn) || e.right.has(fn) }
+func (e *orExpr) has(fn func(e *expression) bool) bool  { return e.left.has(fn) || e.right.has(fn) }
+func (e *notExpr) has(fn func(e *expression) bool) bool { return e.expr.has(fn) }
+func (e *expression) has(fn func(e *expression) bool) bool {
+	return fn(e)
+}
+
+func (e *andExpr) String() string { return fmt.Sprintf("(%s && %s)", e.left, e.right) }
+func (e *orExpr) String() string  { return fmt.Sprintf("(%s || %s)", e.left, e.right) }
+func (e *notExpr) String() string { return fmt.Sprintf("!%s", e.expr) }
+func (e *expression) String() string {
+	return fmt.Sprintf("%s %s %q", e.key, e.operand, e.value)
+}
+
+func (e *expression) is(key string, operand string, value string) bool {
+	return e.key == key && e.operand == operand && e.value == value
+}
+
+func (e *expression) eval(env *env) bool {
+	post := env.post
+	if dateKeys[e.key] {
+		return e.evalDate(env)
+	}
+
+	// Handle group exercises and group posts by only allowing "type == group" and "type == group-post".
+	if post.group {
+		return (e.is("type", "==", "group") && post.exercise) || (e.is("type", "==", "group-post") && !post.exercise)
+	}
+
+	// If the post is a none exercise post only "type == post" is true.
+	if !post.exercise {
+		return e.is("type", "==", "post")
+	}
+
+	switch e.key {
+	case "name":
+		return e.compare(post.name)
+	case "group":
+		return e.compare(post.groupName)
+	case "type":
+		return e.compare(post.trainingType)
+	case "duration":
+		postDur, err := strconv.Atoi(post.trainingDuration)
+		if err != nil {
+			fmt.Printf("couldn't convert post duration %s to int, continuing\n", post.trainingDuration)
+			return false
+		}
+		return e.order(postDur)
+	case "sentiment":
+		// Mixed is treated as negative, just as when choosing comments for negative posts.
+		sentiment := strings.ToLower(string(post.sentiment))
+		match := sentiment != "" && strings.HasPrefix(sentiment, e.value)
+		if e.value == "neg" && post.sentiment == types.SentimentTypeMixed {
+			match = true
+		}
+		return match == (e.operand == "==")
+	}
+
+	return false
+}
+
+func (e *expression) evalDate(env *env) bool {
+	date := env.post.date.In(env.loc)
+
+	switch e.key {
+	case "time":
+		return e.order((date.Hour() * 60) + date.Minute())
+	case "weekday":
+		return e.within(int(date.Weekday()))
+	case "month":
+		return e.within(int(date.Month()))
+	case "date":
+		day := (date.Year() * 10000) + (int(date.Month()) * 100) + date.Day()
+		if e.yearly {
+			day = day % 10000
+		}
+		if e.operand == "==" || e.operand == "!=" {
+			return e.within(day)
+		}
+		return e.order(day)
+	case "age":
+		return e.order(int(env.now.Sub(env.post.date).Minutes()))
+	}
+
+	return false
+}
+
+// within checks if n is in the range of the expression for == and outside of it for !=.
+// Ranges where the end is before the start wrap around, such as sat..mon or dec..feb.
+func (e *expression) within(n int) bool {
+	in := n >= e.number && n <= e.to
+	if e.number > e.to {
+		in = n >= e.number || n <= e.to
+	}
+
+	return in == (e.operand == "==")
+}
+
+func (e *expression) compare(str string) bool {
+	str = strings.ToLower(str)
+
+	switch e.operand {
+	case "==":
+		return str == e.value
+	case "!=":
+		return str != e.value
+	case "~":
+		return strings.Contains(str, e.value)
+	case "!~":
+		return !strings.Contains(str, e.value)
+	case "=~":
+		return e.re.MatchString(str)
+	case "!=~":
+		return !e.re.MatchString(str)
+	}
+
+	return false
+}
+
+func (e *expression) order(n int) bool {
+	switch e.operand {
+	case "==":
+		return n == e.number
+	case "<=":
+		return n <= e.number
+	case ">=":
+		return n >= e.number
+	case "<":
+		return n < e.number
+	case ">":
+		return n > e.number
+	}
+
+	return false
+}
+
+// parseExpr parses an expression such as `(type == running || type == cycling) && !(duration < 45)`.
+// && binds harder than ||. Values extend to the next && or || and can be quoted with "" to contain them.
+func parseExpr(src string) (expr, error) {
+	p := &parser{src: src}
+
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	p.space()
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.src[p.pos:], p.pos+1)
+	}
+
+	return e, nil
+}
+
+type parser struct {
+	src string
+	pos int
+	// depth is the number of open parentheses.
+	depth int
+}
+
+func (p *parser) space() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) consume(token string) bool {
+	p.space()
+	if !strings.HasPrefix(p.src[p.pos:], token) {
+		return false
+	}
+
+	p.pos += len(token)
+	return true
+}
+
+func (p *parser) or() (expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.consume("||") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &orExpr{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) and() (expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.consume("&&") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = &andExpr{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) not() (expr, error) {
+	if p.consume("!") {
+		e, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{expr: e}, nil
+	}
+
+	if p.consume("(") {
+		p.depth++
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, fmt.Errorf("missing ) at position %d", p.pos+1)
+		}
+		p.depth--
+		return e, nil
+	}
+
+	return p.comparison()
+}
+
+func (p *parser) comparison() (expr, error) {
+	p.space()
+	start := p.pos
+	for p.pos < len(p.src) && (p.src[p.pos] >= 'a' && p.src[p.pos] <= 'z' || p.src[p.pos] >= 'A' && p.src[p.pos] <= 'Z') {
+		p.pos++
+	}
+
+	key := strings.ToLower(p.src[start:p.pos])
+	if key == "" {
+		return nil, fmt.Errorf("expected an expression at position %d", start+1)
+	}
+	if _, ok := keyOperands[key]; !ok {
+		return nil, fmt.Errorf("unknown key %q", key)
+	}
+
+	operand := ""
+	p.space()
+	for _, op := range operands {
+		if p.consume(op) {
+			operand = op
+			break
+		}
+	}
+	if operand == "" {
+		return nil, fmt.Errorf("expected an operand after %s at position %d", key, p.pos+1)
+	}
+
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+
+	return newExpression(key, operand, value)
+}
+
+func (p *parser) value() (string, error) {
+	p.space()
+
+	if p.consume(`"`) {
+		b := strings.Builder{}
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			p.pos++
+
+			switch {
+			case c == '\\' && p.pos < len(p.src):
+				b.WriteByte(p.src[p.pos])
+				p.pos++
+			case c == '"':
+				return b.String(), nil
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("missing closing \"")
+	}
+
+	// Parentheses in a value are kept as long as they are balanced, an unbalanced ) closes the group.
+	start, depth := p.pos, 0
+loop:
+	for p.pos < len(p.src) {
+		rest := p.src[p.pos:]
+		switch {
+		case strings.HasPrefix(rest, "&&"), strings.HasPrefix(rest, "||"):
+			break loop
+		case rest[0] == '(':
+			depth++
+		case rest[0] == ')' && depth > 0:
+			depth--
+		case rest[0] == ')' && p.depth > 0:
+			break loop
+		}
+		p.pos++
+	}
+
+	value := strings.TrimSpace(p.src[start:p.pos])
+	if value == "" {
+		return "", fmt.Errorf("missing value at position %d", start+1)
+	}
+
+	return value, nil
+}
+
+func newExpression(key string, operand string, value string) (*expression, error) {
+	e := &expression{key: key, operand: operand, value: strings.ToLower(value)}
+
+	supported := false
+	for _, op := range keyOperands[key] {
+		supported = supported || op == operand
+	}
+	if !supported {
+		return nil, fmt.Errorf("%s doesn't support %s, use one of %s", key, operand, strings.Join(keyOperands[key], " "))
+	}
+
+	// Regular expressions are compiled from the value as written, lowercasing would change escapes such as \S.
+	if operand == "=~" || operand == "!=~" {
+		re, err := regexp.Compile("(?i)" + value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %s. %w", value, err)
+		}
+		e.re = re
+	}
+
+	// Only == and != can be used with ranges.
+	if strings.Contains(e.value, rangeSeparator) && operand != "==" && operand != "!=" {
+		return nil, fmt.Errorf("%s %s can only be used with == and !=", key, value)
+	}
+
+	switch key {
+	case "duration":
+		dur, err := strconv.Atoi(e.value)
+		if err != nil {
+			return nil, fmt.Errorf("duration %s must be a whole number of minutes", value)
+		}
+		e.number = dur
+	case "age":
+		age, err := parseAge(e.value)
+		if err != nil {
+			return nil, fmt.Errorf("age %s must be a whole number of minutes or a duration such as 2h", value)
+		}
+		e.number = age
+	case "weekday":
+		from, to, err := parseRange(e.value, parseWeekday)
+		if err != nil {
+			return nil, fmt.Errorf("weekday %s must be a day such as mon or a range such as sat..sun", value)
+		}
+		e.number, e.to = from, to
+	case "month":
+		from, to, err := parseRange(e.value, parseMonth)
+		if err != nil {
+			return nil, fmt.Errorf("month %s must be a month such as jan or 1 or a range such as jun..aug", value)
+		}
+		e.number, e.to = from, to
+	case "date":
+		// Dates without a year, such as 12-24, match every year.
+		e.yearly = len(strings.TrimSpace(strings.Split(e.value, rangeSeparator)[0])) == len(yearlyDateFormat)
+		from, to, err := parseRange(e.value, func(str string) (int, error) { return parseDate(str, e.yearly) })
+		if err != nil {
+			return nil, fmt.Errorf("date %s must be a date such as 2021-12-24 or 12-24 or a range such as 12-24..12-26", value)
+		}
+		if from > to && !e.yearly {
+			return nil, fmt.Errorf("date range %s ends before it starts", value)
+		}
+		e.number, e.to = from, to
+	case "time":
+		t, err := time.Parse(timeFormat, e.value)
+		if err != nil {
+			return nil, fmt.Errorf("time %s must be in hh:mm format", value)
+		}
+		e.number = (t.Hour() * 60) + t.Minute()
+	}
+
+	return e, nil
+}
+
+// conjuncts returns the expressions joined by && at the top level of e.
+func conjuncts(e expr) []expr {
+	if and, ok := e.(*andExpr); ok {
+		return append(conjuncts(and.left), conjuncts(and.right)...)
+	}
+
+	return []expr{e}
+}
+
+// parseRange parses a single value or a range of two values separated by "..".
+func parseRange(str string, parse func(str string) (int, error)) (int, int, error) {
+	strs := strings.Split(str, rangeSeparator)
+	if len(strs) > 2 {
+		return 0, 0, fmt.Errorf("range %s can only have a start and an end", str)
+	}
+
+	from, err := parse(strings.TrimSpace(strs[0]))
+	if err != nil {
+		return 0, 0, err
+	}
+	to := from
+	if len(strs) == 2 {
+		if to, err = parse(strings.TrimSpace(strs[1])); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return from, to, nil
+}
+
+func parseWeekday(str string) (int, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if len(str) >= 3 && strings.HasPrefix(name, str) {
+			return int(day), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown weekday %s", str)
+}
+
+func parseMonth(str string) (int, error) {
+	if month, err := strconv.Atoi(str); err == nil && month >= 1 && month <= 12 {
+		return month, nil
+	}
+
+	for month := time.January; month <= time.December; month++ {
+		name := strings.ToLower(month.String())
+		if len(str) >= 3 && strings.HasPrefix(name, str) {
+			return int(month), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown month %s", str)
+}
+
+// parseDate parses a date to a number that can be compared, such as 20211224 or 1224 for yearly dates.
+func parseDate(str string, yearly bool) (int, error) {
+	if yearly {
+		date, err := time.Parse(yearlyDateFormat, str)
+		if err != nil {
+			return 0, err
+		}
+		return (int(date.Month()) * 100) + date.Day(), nil
+	}
+
+	date, err := time.Parse(dayFormat, str)
+	if err != nil {
+		return 0, err
+	}
+	return (date.Year() * 10000) + (int(date.Month()) * 100) + date.Day(), nil
+}
+
+// parseAge parses minutes or a duration such as 2h30m to minutes.
+func parseAge(str string) (int, error) {
+	if age, err := strconv.Atoi(str); err == nil {
+		return age, nil
+	}
+
+	age, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, err
+	}
+	return int(age.Minutes()), nil
+}
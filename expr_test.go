@@ -0,0 +1,225 @@
+package weplus
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/comprehend/types"
+)
+
+func TestParseExpr(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+		err  string
+	}{
+		{src: "duration > 90", want: `duration > "90"`},
+		{src: "Type == Running", want: `type == "running"`},
+		{src: "type == running || type == cycling && duration > 45", want: `(type == "running" || (type == "cycling" && duration > "45"))`},
+		{src: "(type == running || type == cycling) && duration > 45", want: `((type == "running" || type == "cycling") && duration > "45")`},
+		{src: "!(name == Big Boss) && !!group ~ hawk", want: `(!name == "big boss" && !!group ~ "hawk")`},
+		{src: "(name == Big Boss (CEO))", want: `name == "big boss (ceo)"`},
+		{src: "name == Smile :)", want: `name == "smile :)"`},
+		{src: `group == "Rock && Roll" || name == "Say \"hi\""`, want: `(group == "rock && roll" || name == "say \"hi\"")`},
+		{src: "time >= 06:30", want: `time >= "06:30"`},
+		{src: "type =~ ^(löpning|running)$ && name !=~ ^big", want: `(type =~ "^(löpning|running)$" && name !=~ "^big")`},
+		{src: "type =~ (yoga", err: "invalid regular expression (yoga. error parsing regexp: missing closing )"},
+		{src: "duration =~ 5", err: "duration doesn't support =~"},
+		{src: "weekday == Sat..Sun", want: `weekday == "sat..sun"`},
+		{src: "weekday == funday", err: "weekday funday must be a day"},
+		{src: "weekday < fri", err: "weekday doesn't support <"},
+		{src: "month == 13", err: "month 13 must be a month"},
+		{src: "date == 2021-12-24..2021-12-26 || date > 2022-01-01", want: `(date == "2021-12-24..2021-12-26" || date > "2022-01-01")`},
+		{src: "date == 2021-12-24..12-26", err: "date 2021-12-24..12-26 must be a date"},
+		{src: "date == 2021-12-26..2021-12-24", err: "date range 2021-12-26..2021-12-24 ends before it starts"},
+		{src: "date > 12-24..12-26", err: "date 12-24..12-26 can only be used with == and !="},
+		{src: "age < 2h", want: `age < "2h"`},
+		{src: "age < soon", err: "age soon must be a whole number of minutes"},
+		{src: "", err: "expected an expression at position 1"},
+		{src: "durration > 5", err: `unknown key "durration"`},
+		{src: "duration => 5", err: "expected an operand after duration at position 10"},
+		{src: "duration > ", err: "missing value at position 12"},
+		{src: "duration > ten", err: "duration ten must be a whole number of minutes"},
+		{src: "time > 25:00", err: "time 25:00 must be in hh:mm format"},
+		{src: "duration ~ 5", err: "duration doesn't support ~"},
+		{src: "(type == yoga || type == running", err: "missing ) at position 33"},
+		{src: "(type == yoga) duration > 5", err: `unexpected "duration > 5" at position 16`},
+		{src: `name == "Big Boss`, err: `missing closing "`},
+		{src: "type == yoga &&", err: "expected an expression at position 16"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.src, func(t *testing.T) {
+			got, err := parseExpr(c.src)
+			switch {
+			case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+				t.Fatalf("expected error %q but got %v", c.err, err)
+			case c.err == "" && err != nil:
+				t.Fatal(err)
+			case c.err == "" && got.String() != c.want:
+				t.Errorf("expected %s but got %s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestEvalExpr(t *testing.T) {
+	running := &post{name: "Big Boss", groupName: "@Hawks", trainingType: "Löpning", trainingDuration: "60", exercise: true, date: time.Date(2021, 4, 1, 6, 30, 0, 0, time.UTC), sentiment: types.SentimentTypePositive}
+	status := &post{name: "Big Boss", exercise: false}
+	group := &post{name: "Big Boss", trainingType: "Löpning", trainingDuration: "60", exercise: true, group: true}
+
+	cases := []struct {
+		src  string
+		post *post
+		want bool
+	}{
+		{src: "type == löpning || type == cykling", post: running, want: true},
+		{src: "(type == yoga || type == cykling) && duration > 45", post: running, want: false},
+		{src: "!(duration < 45) && name ~ boss", post: running, want: true},
+		{src: "group != @hawks || time < 06:00", post: running, want: false},
+		{src: "time >= 06:30 && time < 07:00", post: running, want: true},
+		{src: "type =~ ^(löpning|running)$", post: running, want: true},
+		{src: `name =~ ^big\sB`, post: running, want: true},
+		{src: "group !=~ hawk", post: running, want: false},
+		{src: "type =~ ^running$ || group =~ ^@", post: running, want: true},
+		{src: "sentiment == pos", post: running, want: true},
+		{src: "sentiment != neg", post: running, want: true},
+		{src: "type == post", post: status, want: true},
+		{src: "type == post && name == big boss", post: status, want: false},
+		{src: "type == post || name == big boss", post: status, want: true},
+		{src: "!(name == someone)", post: status, want: true},
+		{src: "type == group", post: group, want: true},
+		{src: "type == group && duration > 45", post: group, want: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.src, func(t *testing.T) {
+			e, err := parseExpr(c.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := e.eval(&env{post: c.post, loc: time.UTC}); got != c.want {
+				t.Errorf("expected %v but got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestValidCommentsPostType(t *testing.T) {
+	comments, err := loadComments([]byte(`version: 1
+rules:
+  - name: not boss
+    match: "!(name == someone)"
+    comments: "{{Duration}} minutes!"
+  - name: posts
+    match: type == post || type == group-post
+    comments: 👍
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rules that don't mention the post type must never match posts, even if they are true.
+	for _, p := range []*post{{name: "Big Boss"}, {name: "Big Boss", group: true}} {
+		valid := validComments(comments, p, time.Now())
+		if len(valid) != 1 || valid[0].name != "posts" {
+			t.Errorf("expected only the posts rule to match %+v but got %d rules", *p, len(valid))
+		}
+	}
+}
+
+func TestValidCommentsSentiment(t *testing.T) {
+	comments, err := loadComments([]byte(`version: 1
+rules:
+  - name: positive
+    match: sentiment == pos
+    comments: Great!
+  - name: negative
+    match: (sentiment == neg)
+    comments: Get well soon
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		sentiment types.SentimentType
+		want      []string
+	}{
+		{sentiment: types.SentimentTypePositive, want: []string{"positive", "negative"}},
+		{sentiment: types.SentimentTypeNeutral, want: []string{"negative"}},
+		{sentiment: types.SentimentTypeNegative, want: []string{"negative"}},
+	}
+
+	for _, c := range cases {
+		t.Run(string(c.sentiment), func(t *testing.T) {
+			got := []string{}
+			for _, comment := range validComments(comments, &post{exercise: true, sentiment: c.sentiment}, time.Now()) {
+				got = append(got, comment.name)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected rules %q but got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestEvalDateExpr(t *testing.T) {
+	stockholm, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Saturday 2021-12-25 23:30 UTC is Sunday 00:30 in Stockholm.
+	run := &post{trainingType: "Löpning", trainingDuration: "60", exercise: true, date: time.Date(2021, 12, 25, 23, 30, 0, 0, time.UTC)}
+	status := &post{date: run.date}
+	now := run.date.Add(90 * time.Minute)
+
+	cases := []struct {
+		src  string
+		post *post
+		loc  *time.Location
+		want bool
+	}{
+		{src: "weekday == sat", post: run, loc: time.UTC, want: true},
+		{src: "weekday == sat", post: run, loc: stockholm, want: false},
+		{src: "weekday == sunday", post: run, loc: stockholm, want: true},
+		{src: "weekday == sat..sun && duration >= 60", post: run, loc: stockholm, want: true},
+		{src: "weekday == fri..mon", post: run, loc: stockholm, want: true},
+		{src: "weekday != mon..fri", post: run, loc: stockholm, want: true},
+		{src: "time < 07:00", post: run, loc: time.UTC, want: false},
+		{src: "time < 07:00", post: run, loc: stockholm, want: true},
+		{src: "month == dec", post: run, loc: time.UTC, want: true},
+		{src: "month == 1", post: run, loc: stockholm, want: false},
+		{src: "month == nov..feb", post: run, loc: stockholm, want: true},
+		{src: "month != jun..aug", post: run, loc: stockholm, want: true},
+		{src: "date == 2021-12-25", post: run, loc: time.UTC, want: true},
+		{src: "date == 2021-12-25", post: run, loc: stockholm, want: false},
+		{src: "date == 2021-12-24..2021-12-26", post: run, loc: stockholm, want: true},
+		{src: "date == 12-24..12-26", post: run, loc: stockholm, want: true},
+		{src: "date == 12-31..01-01", post: run, loc: stockholm, want: false},
+		{src: "date >= 2022-01-01", post: run, loc: stockholm, want: false},
+		{src: "date < 12-26", post: run, loc: stockholm, want: false},
+		{src: "age > 60", post: run, loc: stockholm, want: true},
+		{src: "age < 1h", post: run, loc: stockholm, want: false},
+		{src: "age == 90", post: run, loc: stockholm, want: true},
+		{src: "type == post && weekday == sun", post: status, loc: stockholm, want: true},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%s in %s", c.src, c.loc), func(t *testing.T) {
+			e, err := parseExpr(c.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := e.eval(&env{post: c.post, loc: c.loc, now: now}); got != c.want {
+				t.Errorf("expected %v but got %v", c.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,421 @@
+package weplus
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWeplus is a local stand-in for weplusapp.com. Every rendered page rotates the
+// csrf token and every post request must carry the latest one.
+type fakeWeplus struct {
+	*httptest.Server
+
+	email    string
+	password string
+	userID   string
+	pageSize int
+
+	mu       sync.Mutex
+	tokens   int
+	token    string
+	session  string
+	feeds    map[string][]*fakePost
+	likes    []string
+	comments []*fakeComment
+	// failLike is a post id that is liked but the response is an error, like when
+	// the connection is lost after the like was made.
+	failLike string
+}
+
+type fakePost struct {
+	ID       string
+	UserID   string
+	Name     string
+	Group    string
+	Duration int
+	Kind     string
+	Date     time.Time
+	Text     string
+}
+
+type fakeComment struct {
+	postID string
+	body   string
+}
+
+func newFakeWeplus(t *testing.T) *fakeWeplus {
+	t.Helper()
+
+	fw := &fakeWeplus{
+		email:    "erik@example.com",
+		password: "secret",
+		userID:   "10001",
+		pageSize: 2,
+		feeds:    map[string][]*fakePost{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", fw.login)
+	mux.HandleFunc("/sessions", fw.sessions)
+	mux.HandleFunc("/feed", fw.feed)
+	mux.HandleFunc("/statuses/", fw.status)
+	mux.HandleFunc("/likes", fw.like)
+	mux.HandleFunc("/comments", fw.comment)
+
+	fw.Server = httptest.NewServer(mux)
+	t.Cleanup(fw.Close)
+
+	return fw
+}
+
+// cfg returns a config with its own cookie jar that talks to the fake server.
+func (fw *fakeWeplus) cfg(t *testing.T) *cfg {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &cfg{
+		ctx:      context.Background(),
+		client:   &http.Client{Jar: jar, Timeout: 5 * time.Second},
+		password: fw.password,
+		baseURL:  fw.URL,
+		store:    newMemStore(),
+		rand:     rand.New(rand.NewSource(1)),
+	}
+}
+
+// newStore returns a store with the legacy comments file and an empty state for the user.
+func (fw *fakeWeplus) newStore(comments string) *memStore {
+	store := newMemStore()
+	store.save(fmt.Sprintf("%s.comments.txt", fw.email), []byte(comments))
+	store.save(fmt.Sprintf("%s.json", fw.email), []byte(`{"group":[],"company":[]}`))
+	return store
+}
+
+// newCfg returns a cfg that runs against the fake with the files in store.
+func (fw *fakeWeplus) newCfg(t *testing.T, store store) *cfg {
+	t.Helper()
+
+	cfg, err := newWith(context.Background(), 5000, store, memSecrets{fw.email: fw.password}, memAnalyzer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.baseURL = fw.URL
+
+	return cfg
+}
+
+// run runs the bot for inp with the files in store and fails the test if the run fails.
+func (fw *fakeWeplus) run(t *testing.T, store store, inp *Input) *Report {
+	t.Helper()
+
+	report, err := fw.newCfg(t, store).run(inp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return report
+}
+
+// add adds posts to the feed of feedType, the newest post should be added last.
+func (fw *fakeWeplus) add(feedType string, posts ...*fakePost) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	for _, p := range posts {
+		fw.feeds[feedType] = append([]*fakePost{p}, fw.feeds[feedType]...)
+	}
+}
+
+func (fw *fakeWeplus) liked() []string {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	return append([]string{}, fw.likes...)
+}
+
+func (fw *fakeWeplus) commented() []*fakeComment {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	return append([]*fakeComment{}, fw.comments...)
+}
+
+func (fw *fakeWeplus) rotate() string {
+	fw.tokens++
+	fw.token = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("token-%d", fw.tokens)))
+	return fw.token
+}
+
+func (fw *fakeWeplus) render(w http.ResponseWriter, tmpl *template.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), 500)
+	}
+}
+
+func (fw *fakeWeplus) authorized(w http.ResponseWriter, r *http.Request, csrf string) bool {
+	cookie, err := r.Cookie("_weplus_session")
+	if err != nil || fw.session == "" || cookie.Value != fw.session {
+		http.Error(w, "not logged in", 401)
+		return false
+	}
+	if r.Method == "POST" && csrf != fw.token {
+		http.Error(w, fmt.Sprintf("invalid authenticity token %q", csrf), 422)
+		return false
+	}
+	return true
+}
+
+func (fw *fakeWeplus) login(w http.ResponseWriter, r *http.Request) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	fw.render(w, fakeLoginTmpl, map[string]interface{}{"Token": fw.rotate()})
+}
+
+func (fw *fakeWeplus) sessions(w http.ResponseWriter, r *http.Request) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if r.PostForm.Get("authenticity_token") != fw.token {
+		http.Error(w, "invalid authenticity token", 422)
+		return
+	}
+
+	data := map[string]interface{}{"Token": fw.rotate()}
+	if !strings.EqualFold(r.PostForm.Get("email"), fw.email) || r.PostForm.Get("password") != fw.password {
+		data["Invalid"] = true
+		fw.render(w, fakeSessionsTmpl, data)
+		return
+	}
+
+	fw.session = fmt.Sprintf("session-%d", fw.tokens)
+	http.SetCookie(w, &http.Cookie{Name: "_weplus_session", Value: fw.session, Path: "/"})
+	data["UserID"] = fw.userID
+	fw.render(w, fakeSessionsTmpl, data)
+}
+
+func (fw *fakeWeplus) feed(w http.ResponseWriter, r *http.Request) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if !fw.authorized(w, r, "") {
+		return
+	}
+
+	qs := r.URL.Query()
+	offset, err := strconv.Atoi(qs.Get("offset"))
+	if err != nil {
+		http.Error(w, "invalid offset", 400)
+		return
+	}
+
+	posts := fw.feeds[qs.Get("type")]
+	end, more := offset+fw.pageSize, ""
+	switch {
+	case offset > len(posts):
+		posts = nil
+	case end < len(posts):
+		posts, more = posts[offset:end], strconv.Itoa(end)
+	default:
+		posts = posts[offset:]
+	}
+
+	liked := map[string]bool{}
+	for _, id := range fw.likes {
+		liked[id] = true
+	}
+
+	fw.render(w, fakeFeedTmpl, map[string]interface{}{
+		"Token":  fw.rotate(),
+		"Posts":  posts,
+		"Liked":  liked,
+		"More":   more,
+		"Type":   qs.Get("type"),
+		"Sort":   qs.Get("sort"),
+		"Filter": qs.Get("filter"),
+	})
+}
+
+func (fw *fakeWeplus) find(id string) *fakePost {
+	for _, posts := range fw.feeds {
+		for _, p := range posts {
+			if p.ID == id {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+func (fw *fakeWeplus) status(w http.ResponseWriter, r *http.Request) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if !fw.authorized(w, r, "") {
+		return
+	}
+
+	p := fw.find(strings.TrimPrefix(r.URL.Path, "/statuses/"))
+	if p == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	comments := []string{}
+	for _, c := range fw.comments {
+		if c.postID == p.ID {
+			comments = append(comments, c.body)
+		}
+	}
+
+	fw.render(w, fakeStatusTmpl, map[string]interface{}{
+		"Token":    fw.rotate(),
+		"Post":     p,
+		"UserID":   fw.userID,
+		"Comments": comments,
+	})
+}
+
+func (fw *fakeWeplus) like(w http.ResponseWriter, r *http.Request) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if !fw.authorized(w, r, r.Header.Get("X-CSRF-Token")) {
+		return
+	}
+	if err := r.ParseForm(); err != nil || fw.find(r.PostForm.Get("like[status_id]")) == nil {
+		http.Error(w, "unknown status", 404)
+		return
+	}
+	// Liking is a toggle like on weplusapp.com, liking a liked post unlikes it.
+	id := r.PostForm.Get("like[status_id]")
+	w.Header().Set("Content-Type", "text/javascript")
+	for i, liked := range fw.likes {
+		if liked == id {
+			fw.likes = append(fw.likes[:i:i], fw.likes[i+1:]...)
+			fmt.Fprintf(w, `$("#%s").removeClass("liked");`, r.PostForm.Get("link_css_id"))
+			return
+		}
+	}
+
+	fw.likes = append(fw.likes, id)
+	if id == fw.failLike {
+		http.Error(w, "internal server error", 500)
+		return
+	}
+	fmt.Fprintf(w, `$("#%s").addClass("liked");`, r.PostForm.Get("link_css_id"))
+}
+
+func (fw *fakeWeplus) comment(w http.ResponseWriter, r *http.Request) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if !fw.authorized(w, r, r.Header.Get("X-CSRF-Token")) {
+		return
+	}
+	if err := r.ParseForm(); err != nil || fw.find(r.PostForm.Get("comment[status_id]")) == nil {
+		http.Error(w, "unknown status", 404)
+		return
+	}
+
+	fw.comments = append(fw.comments, &fakeComment{
+		postID: r.PostForm.Get("comment[status_id]"),
+		body:   r.PostForm.Get("comment[body]"),
+	})
+	w.Header().Set("Content-Type", "text/javascript")
+	fmt.Fprintf(w, `$("#%s").append("<li></li>");`, r.PostForm.Get("comments_css_id"))
+}
+
+var fakeFuncs = template.FuncMap{
+	"exact": func(d time.Time) string { return d.Format(dateFormat) },
+}
+
+var fakeLoginTmpl = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta name="csrf-param" content="authenticity_token" />
+<meta name="csrf-token" content="{{.Token}}" />
+</head>
+<body>
+<form class="login-form" action="/sessions" method="post"><input type="hidden" name="authenticity_token" value="{{.Token}}" /></form>
+</body>
+</html>
+`))
+
+var fakeSessionsTmpl = template.Must(template.New("sessions").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta name="csrf-param" content="authenticity_token" />
+<meta name="csrf-token" content="{{.Token}}" />
+</head>
+<body>
+{{if .Invalid}}<div class="alert alert-danger">Email eller lösenord är ogiltiga</div>{{end}}
+{{if .UserID}}<ul class="dropdown-menu">
+<li>
+<a href="/users/{{.UserID}}">
+<i class="fas fa-chart-bar"></i>
+My Statistics
+</a>
+</li>
+</ul>{{end}}
+</body>
+</html>
+`))
+
+var fakeFeedTmpl = template.Must(template.New("feed").Funcs(fakeFuncs).Parse(`<meta name="csrf-token" content="{{.Token}}" />
+<ul class="feed-items">
+{{range .Posts}}<li class="feed-item" id="status-{{.ID}}">
+  <div class="post-header">
+    <h3 class="post-author">
+      <strong><a href="/users/{{.UserID}}">{{.Name}}</a></strong>
+    </h3>
+    <div class="post-group-name">{{.Group}}</div>
+    {{if .Kind}}<p class="post-status-string"><a href="/statuses/{{.ID}}"><i class="fas fa-check fa-xs"></i> {{.Duration}} minutes</a> of <a class="exercise-type" href="/exercises?exercise_type_name={{.Kind}}">{{.Kind}}</a> <a class="ago-in-words ago timeago" id="exercise-{{.ID}}-happened-at-ago" data-toggle-id="exercise-{{.ID}}-happened-at-exact-time">ago</a><a class="ago-in-words exact-time" id="exercise-{{.ID}}-happened-at-exact-time" data-toggle-id="exercise-{{.ID}}-happened-at-ago">{{exact .Date}}</a></p>
+    {{- else}}<p class="post-status-string"><a href="/statuses/{{.ID}}"><i class="fas fa-check fa-xs"></i> Post</a> <a class="ago-in-words ago timeago" id="post-{{.ID}}-happened-at-ago" data-toggle-id="post-{{.ID}}-happened-at-exact-time">ago</a><a class="ago-in-words exact-time" id="post-{{.ID}}-happened-at-exact-time" data-toggle-id="post-{{.ID}}-happened-at-ago">{{exact .Date}}</a></p>{{end}}
+  </div>
+  <div class="post-actions">
+    {{if index $.Liked .ID}}<a class="like-link liked" id="like-status-{{.ID}}" data-remote="true" href="/likes">Unlike</a>
+    {{- else}}<a class="like-link" id="like-status-{{.ID}}" data-remote="true" href="/likes">Like</a>{{end}}
+    <a class="comment-link" href="/statuses/{{.ID}}">Comment</a>
+  </div>
+</li>
+{{end}}{{if .More}}<li class="feed-more-item" data-type="{{.Type}}" data-offset="{{.More}}" data-limit="12" data-sort="{{.Sort}}" data-filter="{{.Filter}}"></li>{{end}}
+</ul>
+`))
+
+var fakeStatusTmpl = template.Must(template.New("status").Parse(`<meta name="csrf-token" content="{{.Token}}" />
+<div class="status" id="status-{{.Post.ID}}">
+<div class="post-body">
+<p>{{.Post.Text}}</p></div>
+<ul class="comments-list" id="comments-list-{{.Post.ID}}">
+{{range .Comments}}<li class="comment">
+  <strong><a href="/users/{{$.UserID}}">Erik Ek</a></strong>
+  <p class="comment-body">{{.}}</p>
+</li>
+{{end}}</ul>
+</div>
+`))
@@ -6,8 +6,11 @@ require (
 	github.com/aws/aws-lambda-go v1.23.0
 	github.com/aws/aws-sdk-go-v2/config v1.1.3
 	github.com/aws/aws-sdk-go-v2/service/cloudwatchevents v1.2.0
-	github.com/aws/aws-sdk-go-v2/service/comprehend v1.3.0 // indirect
+	github.com/aws/aws-sdk-go-v2/service/comprehend v1.3.0
 	github.com/aws/aws-sdk-go-v2/service/kms v1.2.0
 	github.com/aws/aws-sdk-go-v2/service/lambda v1.2.0
 	github.com/aws/aws-sdk-go-v2/service/s3 v1.3.0
+	github.com/aws/smithy-go v1.3.0
+	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,127 @@
+package weplus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RunLocal runs the bot for the user in inp without any AWS dependencies. Comments and
+// state files are read from and written to dir and no sentiment analysis is made.
+func RunLocal(ctx context.Context, inp *Input, dir string, password string) (*Report, error) {
+	cfg, err := newWith(ctx, 15000, &dirStore{dir: dir}, staticSecrets(password), memAnalyzer{})
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.run(inp)
+}
+
+type dirStore struct {
+	dir string
+}
+
+func (d *dirStore) download(file string) ([]byte, error) {
+	path := filepath.Join(d.dir, file)
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("couldn't find %s. %w", path, errNotFound)
+		}
+		return nil, fmt.Errorf("couldn't read file %s. %w", path, err)
+	}
+
+	return raw, nil
+}
+
+func (d *dirStore) downloadVersion(file string) ([]byte, string, error) {
+	raw, err := d.download(file)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return raw, versionOf(raw), nil
+}
+
+// saveIf checks the version and saves the file in two steps, which is enough with the
+// lock file held. Lock files are created exclusively.
+func (d *dirStore) saveIf(file string, raw []byte, version string) (string, error) {
+	path := filepath.Join(d.dir, file)
+
+	if version == "" {
+		if err := os.MkdirAll(d.dir, 0o700); err != nil {
+			return "", fmt.Errorf("couldn't create state directory %s. %w", d.dir, err)
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			if os.IsExist(err) {
+				return "", fmt.Errorf("couldn't create %s. %w", path, errConflict)
+			}
+			return "", fmt.Errorf("couldn't create file %s. %w", path, err)
+		}
+		_, err = f.Write(raw)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return "", fmt.Errorf("couldn't write file %s. %w", path, err)
+		}
+		return versionOf(raw), nil
+	}
+
+	cur, _, err := d.downloadVersion(file)
+	if err != nil && !errors.Is(err, errNotFound) {
+		return "", err
+	}
+	if err != nil || versionOf(cur) != version {
+		return "", fmt.Errorf("couldn't save %s. %w", path, errConflict)
+	}
+
+	if err := d.save(file, raw); err != nil {
+		return "", err
+	}
+
+	return versionOf(raw), nil
+}
+
+func (d *dirStore) remove(file string) error {
+	path := filepath.Join(d.dir, file)
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("couldn't remove file %s. %w", path, err)
+	}
+
+	return nil
+}
+
+func (d *dirStore) save(file string, raw []byte) error {
+	path := filepath.Join(d.dir, file)
+
+	if err := os.MkdirAll(d.dir, 0o700); err != nil {
+		return fmt.Errorf("couldn't create state directory %s. %w", d.dir, err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a half written state file.
+	tmp := fmt.Sprintf("%s.tmp", path)
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("couldn't write file %s. %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("couldn't move %s to %s. %w", tmp, path, err)
+	}
+
+	return nil
+}
+
+type staticSecrets string
+
+func (s staticSecrets) getPassword(email string) (string, error) {
+	if s == "" {
+		return "", fmt.Errorf("no password set for %s", email)
+	}
+
+	return string(s), nil
+}
@@ -0,0 +1,62 @@
+package weplus
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRunLocal(t *testing.T) {
+	fw := newFakeWeplus(t)
+	fw.add("group",
+		&fakePost{ID: "2000001", UserID: "10002", Name: "Cecilia Carlsson", Group: "@Hawks", Duration: 30, Kind: "Yoga", Date: date(t, "Sat, 20 Mar 2021 06:00:00 +0100")},
+	)
+
+	prev := os.Getenv("BASE_URL")
+	os.Setenv("BASE_URL", fw.URL)
+	defer os.Setenv("BASE_URL", prev)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "erik@example.com.comments.txt"), []byte("| type == group | Go {{Name}}!"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Don't remember comments so the state is only the seen posts.
+	window := 0
+	inp := &Input{Email: fw.email, MarkAsSeen: true, CommentWindowDays: &window}
+	if _, err := RunLocal(context.Background(), inp, dir, "wrong"); err == nil {
+		t.Fatal("expected wrong password to fail")
+	}
+	if _, err := RunLocal(context.Background(), inp, dir, fw.password); err != nil {
+		t.Fatal(err)
+	}
+
+	fw.add("group",
+		&fakePost{ID: "2000002", UserID: "10003", Name: "David Dahl", Group: "@Hawks", Duration: 45, Kind: "Löpning", Date: date(t, "Sat, 20 Mar 2021 07:00:00 +0100")},
+	)
+
+	inp.MarkAsSeen = false
+	if _, err := RunLocal(context.Background(), inp, dir, fw.password); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"2000002"}; !reflect.DeepEqual(fw.liked(), want) {
+		t.Errorf("expected likes %q but got %q", want, fw.liked())
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "erik@example.com.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &state); err != nil {
+		t.Fatal(err)
+	}
+	group, _ := state["group"].(map[string]interface{})
+	if len(state) != 3 || state["version"] != 2.0 || len(group) != 2 || group["2000001"] == nil || group["2000002"] == nil || state["company"] != nil {
+		t.Errorf("expected state with the 2 seen group posts but got %s", raw)
+	}
+}
@@ -0,0 +1,97 @@
+package weplus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A run holds the lock of the user for at most lockTTL, the longest a lambda can run.
+// The lock of a run that failed without releasing it is taken over when it expires.
+const lockTTL = 15 * time.Minute
+
+var (
+	errLocked   = errors.New("run already in progress")
+	errConflict = errors.New("file was changed by another run")
+)
+
+// lease is the lock file of a user while a run is in progress.
+type lease struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// lock takes the lock of the user so no other run can change the state at the same
+// time. It returns errLocked if another run holds it.
+func (cfg *cfg) lock(email string) error {
+	file := fmt.Sprintf("%s.lock", strings.ToLower(email))
+
+	raw, version, err := cfg.store.downloadVersion(file)
+	switch {
+	case errors.Is(err, errNotFound):
+		version = ""
+	case err != nil:
+		return fmt.Errorf("couldn't read lock of %s. %w", email, err)
+	default:
+		l := &lease{}
+		if err := json.Unmarshal(raw, l); err == nil && time.Now().Before(l.Expires) {
+			return errLocked
+		}
+		cfg.warn("taking over the expired lock of %s", email)
+	}
+
+	owner, err := newSeed()
+	if err != nil {
+		return err
+	}
+	cfg.owner = fmt.Sprintf("%016x", uint64(owner))
+
+	raw, err = json.Marshal(&lease{Owner: cfg.owner, Expires: time.Now().Add(lockTTL)})
+	if err != nil {
+		return fmt.Errorf("couldn't json marshal lock of %s. %w", email, err)
+	}
+
+	// Only one of the runs that read the same lock version can save it.
+	if _, err := cfg.store.saveIf(file, raw, version); err != nil {
+		if errors.Is(err, errConflict) {
+			return errLocked
+		}
+		return fmt.Errorf("couldn't save lock of %s. %w", email, err)
+	}
+
+	return nil
+}
+
+// unlock releases the lock of the user, unless another run took it over.
+func (cfg *cfg) unlock(email string) {
+	if cfg.owner == "" {
+		return
+	}
+	file := fmt.Sprintf("%s.lock", strings.ToLower(email))
+
+	raw, _, err := cfg.store.downloadVersion(file)
+	if err != nil {
+		cfg.warn("couldn't read lock of %s. %s", email, err.Error())
+		return
+	}
+	l := &lease{}
+	if err := json.Unmarshal(raw, l); err != nil || l.Owner != cfg.owner {
+		cfg.warn("lock of %s was taken over by another run", email)
+		return
+	}
+
+	if err := cfg.store.remove(file); err != nil {
+		cfg.warn("couldn't remove lock of %s. %s", email, err.Error())
+	}
+	cfg.owner = ""
+}
+
+// versionOf is the version of a file in stores without versions of their own.
+func versionOf(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,119 @@
+package weplus
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSaveIf(t *testing.T) {
+	stores := map[string]store{
+		"memory": newMemStore(),
+		"dir":    &dirStore{dir: t.TempDir()},
+	}
+
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			v1, err := s.saveIf("a.json", []byte("1"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.saveIf("a.json", []byte("2"), ""); !errors.Is(err, errConflict) {
+				t.Fatalf("expected creating an existing file to conflict but got %v", err)
+			}
+
+			v2, err := s.saveIf("a.json", []byte("2"), v1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.saveIf("a.json", []byte("3"), v1); !errors.Is(err, errConflict) {
+				t.Fatalf("expected saving an old version to conflict but got %v", err)
+			}
+
+			raw, version, err := s.downloadVersion("a.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(raw) != "2" || version != v2 {
+				t.Errorf("expected 2 at version %s but got %s at %s", v2, raw, version)
+			}
+
+			if err := s.remove("a.json"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.download("a.json"); !errors.Is(err, errNotFound) {
+				t.Errorf("expected the file to be removed but got %v", err)
+			}
+		})
+	}
+}
+
+func TestRunLocked(t *testing.T) {
+	fw := newFakeWeplus(t)
+	fw.add("group",
+		&fakePost{ID: "2000001", UserID: "10002", Name: "Cecilia Carlsson", Group: "@Hawks", Duration: 30, Kind: "Yoga", Date: time.Now().Add(-2 * time.Hour)},
+	)
+
+	cases := []struct {
+		name    string
+		expires time.Time
+		locked  bool
+	}{
+		{name: "held", expires: time.Now().Add(time.Minute), locked: true},
+		{name: "expired", expires: time.Now().Add(-time.Minute)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := fw.newStore("| type == group | Nice")
+			raw, _ := json.Marshal(&lease{Owner: "other", Expires: c.expires})
+			store.save("erik@example.com.lock", raw)
+
+			report := fw.run(t, store, &Input{Email: fw.email})
+			if report.Locked != c.locked {
+				t.Fatalf("expected locked %t but got %+v", c.locked, report)
+			}
+
+			state, _ := store.download("erik@example.com.json")
+			if c.locked {
+				if report.Message != "run already in progress, nothing was done" || len(report.Actions) != 0 || string(state) != `{"group":[],"company":[]}` {
+					t.Errorf("expected nothing to be done but got %+v and state %s", report, state)
+				}
+				return
+			}
+
+			if report.Counts.Liked != 1 {
+				t.Errorf("expected the post to be liked but got %+v", report.Counts)
+			}
+			if _, err := store.download("erik@example.com.lock"); !errors.Is(err, errNotFound) {
+				t.Errorf("expected the lock to be released but got %v", err)
+			}
+		})
+	}
+}
+
+func TestSaveStateConflict(t *testing.T) {
+	fw := newFakeWeplus(t)
+	store := fw.newStore("| type == group | Nice")
+
+	cfg := fw.newCfg(t, store)
+	inp := &Input{Email: fw.email}
+	if err := cfg.parse(inp); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _, err := cfg.load(inp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.save(inp, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another run saves the state, so this run can't save over it.
+	store.save("erik@example.com.json", []byte(`{"version":2,"group":{"2000001":"2021-03-20T12:00:00Z"},"company":{}}`))
+	if err := cfg.save(inp, data); !errors.Is(err, errConflict) {
+		t.Errorf("expected a conflict but got %v", err)
+	}
+}
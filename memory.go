@@ -0,0 +1,90 @@
+package weplus
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/comprehend/types"
+)
+
+type memStore struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newMemStore() *memStore {
+	return &memStore{files: map[string][]byte{}}
+}
+
+func (m *memStore) download(file string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	raw, ok := m.files[file]
+	if !ok {
+		return nil, fmt.Errorf("couldn't find %s in memory. %w", file, errNotFound)
+	}
+
+	return append([]byte{}, raw...), nil
+}
+
+func (m *memStore) downloadVersion(file string) ([]byte, string, error) {
+	raw, err := m.download(file)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return raw, versionOf(raw), nil
+}
+
+func (m *memStore) save(file string, raw []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.files[file] = append([]byte{}, raw...)
+	return nil
+}
+
+func (m *memStore) saveIf(file string, raw []byte, version string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cur, ok := m.files[file]
+	if (!ok && version != "") || (ok && versionOf(cur) != version) {
+		return "", fmt.Errorf("couldn't save %s in memory. %w", file, errConflict)
+	}
+
+	m.files[file] = append([]byte{}, raw...)
+	return versionOf(raw), nil
+}
+
+func (m *memStore) remove(file string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.files, file)
+	return nil
+}
+
+type memSecrets map[string]string
+
+func (m memSecrets) getPassword(email string) (string, error) {
+	pass, ok := m[strings.ToLower(email)]
+	if !ok {
+		return "", fmt.Errorf("couldn't find password for %s", email)
+	}
+
+	return pass, nil
+}
+
+// memAnalyzer returns the sentiment set for a text and neutral for all other texts.
+type memAnalyzer map[string]types.SentimentType
+
+func (m memAnalyzer) sentiment(text string) (types.SentimentType, error) {
+	if sentiment, ok := m[text]; ok {
+		return sentiment, nil
+	}
+
+	return types.SentimentTypeNeutral, nil
+}
@@ -0,0 +1,277 @@
+package weplus
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type feedPage struct {
+	posts []*post
+	more  string
+}
+
+type parseError struct {
+	postID  string
+	element string
+	detail  string
+}
+
+func (e *parseError) Error() string {
+	str := fmt.Sprintf("couldn't find %s in feed item", e.element)
+	if e.postID != "" {
+		str = fmt.Sprintf("%s for post id %s", str, e.postID)
+	}
+	if e.detail != "" {
+		str = fmt.Sprintf("%s. %s", str, e.detail)
+	}
+	return str
+}
+
+// parseFeed parses a feed page into posts. Feed items that are missing any expected
+// element are skipped and returned as *parseError in the slice of errors.
+func parseFeed(r io.Reader, feedType string, sort string, filter string) (*feedPage, []error, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't parse feed html. %w", err)
+	}
+
+	page := &feedPage{posts: []*post{}}
+	errs := []error{}
+
+	for _, status := range findAll(doc, withClass(atom.P, "post-status-string")) {
+		post, err := parseItem(status)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		page.posts = append(page.posts, post)
+	}
+
+	for _, more := range findAll(doc, withClass(atom.Li, "feed-more-item")) {
+		if attr(more, "data-type") == feedType && attr(more, "data-sort") == sort && attr(more, "data-filter") == filter {
+			page.more = attr(more, "data-offset")
+			break
+		}
+	}
+
+	return page, errs, nil
+}
+
+func parseItem(status *html.Node) (*post, error) {
+	data := &post{}
+
+	link := find(status, func(n *html.Node) bool {
+		return n.DataAtom == atom.A && strings.HasPrefix(attr(n, "href"), "/statuses/")
+	})
+	if link == nil {
+		return nil, &parseError{element: "status link"}
+	}
+	data.postID = strings.TrimPrefix(attr(link, "href"), "/statuses/")
+	if !isNumber(data.postID) {
+		return nil, &parseError{element: "status id", detail: fmt.Sprintf("got %q", data.postID)}
+	}
+
+	// The author and group are siblings of the status string, so walk up as long
+	// as we don't reach an element that also contains other feed items.
+	item := status
+	for item.Parent != nil && len(findAll(item.Parent, withClass(atom.P, "post-status-string"))) == 1 {
+		item = item.Parent
+	}
+
+	group := find(item, withClass(atom.Div, "post-group-name"))
+	if group == nil {
+		return nil, &parseError{postID: data.postID, element: "post group name"}
+	}
+	data.groupName = text(group)
+
+	author := find(item, func(n *html.Node) bool {
+		return n.DataAtom == atom.A && strings.HasPrefix(attr(n, "href"), "/users/") &&
+			n.Parent != nil && n.Parent.DataAtom == atom.Strong
+	})
+	if author == nil {
+		return nil, &parseError{postID: data.postID, element: "author link"}
+	}
+	data.userID = strings.TrimPrefix(attr(author, "href"), "/users/")
+	if !isNumber(data.userID) {
+		return nil, &parseError{postID: data.postID, element: "author id", detail: fmt.Sprintf("got %q", data.userID)}
+	}
+	data.name = text(author)
+	data.likeState = parseLike(item, data.postID)
+
+	exact := find(status, withClass(atom.A, "exact-time"))
+	if exact == nil {
+		return nil, &parseError{postID: data.postID, element: "exact time"}
+	}
+	date, err := time.Parse(dateFormat, text(exact))
+	if err != nil {
+		return nil, &parseError{postID: data.postID, element: "exact time", detail: err.Error()}
+	}
+	data.date = date
+
+	// Exercises have the exercise id prefix on the time elements and a duration
+	// in the status link, plain posts only say "Post".
+	if !strings.HasPrefix(attr(exact, "id"), "exercise-") {
+		return data, nil
+	}
+	data.exercise = true
+
+	duration := strings.Fields(text(link))
+	if len(duration) == 0 || !isNumber(duration[0]) {
+		return nil, &parseError{postID: data.postID, element: "exercise duration", detail: fmt.Sprintf("got %q", text(link))}
+	}
+	data.trainingDuration = duration[0]
+
+	exerciseType := find(status, withClass(atom.A, "exercise-type"))
+	if exerciseType == nil {
+		return nil, &parseError{postID: data.postID, element: "exercise type"}
+	}
+	data.trainingType = text(exerciseType)
+
+	return data, nil
+}
+
+// likeState is if the logged in user likes a post, as shown by its like link in the feed.
+type likeState int
+
+const (
+	likeUnknown likeState = iota
+	likeNotLiked
+	likeLiked
+)
+
+// parseLike parses the like link of the post. Only the liked class and the Like and
+// Unlike texts are trusted, anything else such as another language is unknown.
+func parseLike(item *html.Node, postID string) likeState {
+	like := find(item, func(n *html.Node) bool {
+		return n.DataAtom == atom.A && attr(n, "id") == fmt.Sprintf("like-status-%s", postID)
+	})
+	switch {
+	case like == nil:
+		return likeUnknown
+	case hasClass(like, "liked") || text(like) == "Unlike":
+		return likeLiked
+	case text(like) == "Like":
+		return likeNotLiked
+	}
+	return likeUnknown
+}
+
+func find(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := find(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func findAll(n *html.Node, match func(*html.Node) bool) []*html.Node {
+	found := []*html.Node{}
+	if n.Type == html.ElementNode && match(n) {
+		found = append(found, n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		found = append(found, findAll(c, match)...)
+	}
+	return found
+}
+
+func withClass(tag atom.Atom, class string) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		return n.DataAtom == tag && hasClass(n, class)
+	}
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func text(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+
+	b := &strings.Builder{}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return strings.TrimSpace(b.String())
+}
+
+func isNumber(str string) bool {
+	_, err := strconv.Atoi(str)
+	return str != "" && err == nil
+}
+
+// status is what the status page of a post shows for the logged in user.
+type status struct {
+	text     string
+	comments []string
+}
+
+// parseStatus parses the comments made by the user from a status page.
+func parseStatus(r io.Reader, postID string, userID string) (*status, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse status html. %w", err)
+	}
+
+	st := &status{comments: []string{}}
+
+	list := find(doc, func(n *html.Node) bool {
+		return n.DataAtom == atom.Ul && attr(n, "id") == fmt.Sprintf("comments-list-%s", postID)
+	})
+	if list != nil {
+		st.comments = parseComments(list, userID)
+	}
+
+	return st, nil
+}
+
+// parseComments returns the comments in the list made by the user.
+func parseComments(list *html.Node, userID string) []string {
+	comments := []string{}
+
+	for _, comment := range findAll(list, withClass(atom.Li, "comment")) {
+		author := find(comment, func(n *html.Node) bool {
+			return n.DataAtom == atom.A && attr(n, "href") == fmt.Sprintf("/users/%s", userID)
+		})
+		if author == nil {
+			continue
+		}
+		comments = append(comments, text(find(comment, withClass(atom.P, "comment-body"))))
+	}
+
+	return comments
+}
@@ -0,0 +1,191 @@
+package weplus
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseFeed(t *testing.T) {
+	cases := []struct {
+		file     string
+		feedType string
+		filter   string
+		want     []*post
+		more     string
+		errs     []*parseError
+	}{
+		{
+			file:     "feed_group_0.html",
+			feedType: "group",
+			filter:   "all",
+			want: []*post{
+				{
+					exercise: true, date: date(t, "Sat, 20 Mar 2021 07:15:00 +0100"),
+					postID: "2000003", userID: "10002", name: "Cecilia Carlsson", groupName: "@Save the Hawk Foundation", likeState: likeNotLiked,
+					trainingDuration: "45", trainingType: "Löpning",
+				},
+				{
+					date:   date(t, "Sat, 20 Mar 2021 06:30:00 +0100"),
+					postID: "2000002", userID: "10003", name: "David Dahl", groupName: "@Save the Hawk Foundation", likeState: likeNotLiked,
+				},
+				{
+					exercise: true, date: date(t, "Sat, 20 Mar 2021 06:00:00 +0100"),
+					postID: "2000001", userID: "10001", name: "Erik Ek", groupName: "@Save the Hawk Foundation", likeState: likeNotLiked,
+					trainingDuration: "30", trainingType: "Yoga",
+				},
+			},
+			more: "12",
+		},
+		{
+			file:     "feed_group_0.html",
+			feedType: "group",
+			filter:   "image-or-video",
+			want: []*post{
+				{
+					exercise: true, date: date(t, "Sat, 20 Mar 2021 07:15:00 +0100"),
+					postID: "2000003", userID: "10002", name: "Cecilia Carlsson", groupName: "@Save the Hawk Foundation", likeState: likeNotLiked,
+					trainingDuration: "45", trainingType: "Löpning",
+				},
+				{
+					date:   date(t, "Sat, 20 Mar 2021 06:30:00 +0100"),
+					postID: "2000002", userID: "10003", name: "David Dahl", groupName: "@Save the Hawk Foundation", likeState: likeNotLiked,
+				},
+				{
+					exercise: true, date: date(t, "Sat, 20 Mar 2021 06:00:00 +0100"),
+					postID: "2000001", userID: "10001", name: "Erik Ek", groupName: "@Save the Hawk Foundation", likeState: likeNotLiked,
+					trainingDuration: "30", trainingType: "Yoga",
+				},
+			},
+		},
+		{
+			file:     "feed_company_12.html",
+			feedType: "company",
+			filter:   "image-or-video",
+			want: []*post{
+				{
+					exercise: true, date: date(t, "Fri, 19 Mar 2021 21:10:00 +0100"),
+					postID: "2999999", userID: "10006", name: "Hanna Holm & Co", groupName: "@Competitors", likeState: likeNotLiked,
+					trainingDuration: "20", trainingType: "Yoga",
+				},
+			},
+			errs: []*parseError{{postID: "2999998", element: "exact time"}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.file+" "+c.filter, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join("testdata", c.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			page, errs, err := parseFeed(strings.NewReader(string(raw)), c.feedType, "created-at", c.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			comparePosts(t, page.posts, c.want)
+			if page.more != c.more {
+				t.Errorf("expected more offset %q but got %q", c.more, page.more)
+			}
+
+			if len(errs) != len(c.errs) {
+				t.Fatalf("expected %d errors but got %v", len(c.errs), errs)
+			}
+			for i, want := range c.errs {
+				got := &parseError{}
+				if !errors.As(errs[i], &got) {
+					t.Fatalf("expected *parseError but got %T", errs[i])
+				}
+				if got.postID != want.postID || got.element != want.element {
+					t.Errorf("expected missing %s for %s but got %s", want.element, want.postID, got.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestParseFeedMissingElements(t *testing.T) {
+	cases := []struct {
+		name    string
+		body    string
+		element string
+	}{
+		{
+			name:    "no author",
+			body:    `<li><div class="post-group-name">@G</div><p class="post-status-string"><a href="/statuses/1000001">Post</a><a class="exact-time" id="post-1000001-happened-at-exact-time">Sat, 20 Mar 2021 07:15:00 +0100</a></p></li>`,
+			element: "author link",
+		},
+		{
+			name:    "no group",
+			body:    `<li><h3><strong><a href="/users/10002">A</a></strong></h3><p class="post-status-string"><a href="/statuses/1000001">Post</a><a class="exact-time" id="post-1000001-happened-at-exact-time">Sat, 20 Mar 2021 07:15:00 +0100</a></p></li>`,
+			element: "post group name",
+		},
+		{
+			name:    "no status link",
+			body:    `<li><h3><strong><a href="/users/10002">A</a></strong></h3><div class="post-group-name">@G</div><p class="post-status-string">Post</p></li>`,
+			element: "status link",
+		},
+		{
+			name:    "no exercise type",
+			body:    `<li><h3><strong><a href="/users/10002">A</a></strong></h3><div class="post-group-name">@G</div><p class="post-status-string"><a href="/statuses/1000001">30 minutes</a><a class="exact-time" id="exercise-1000001-happened-at-exact-time">Sat, 20 Mar 2021 07:15:00 +0100</a></p></li>`,
+			element: "exercise type",
+		},
+		{
+			name:    "no duration",
+			body:    `<li><h3><strong><a href="/users/10002">A</a></strong></h3><div class="post-group-name">@G</div><p class="post-status-string"><a href="/statuses/1000001">minutes</a><a class="exercise-type">Yoga</a><a class="exact-time" id="exercise-1000001-happened-at-exact-time">Sat, 20 Mar 2021 07:15:00 +0100</a></p></li>`,
+			element: "exercise duration",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			page, errs, err := parseFeed(strings.NewReader(c.body), "group", "created-at", "all")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.posts) != 0 {
+				t.Errorf("expected no posts but got %d", len(page.posts))
+			}
+			if len(errs) != 1 {
+				t.Fatalf("expected 1 error but got %v", errs)
+			}
+			if got := errs[0].(*parseError); got.element != c.element {
+				t.Errorf("expected missing %s but got %s", c.element, got.Error())
+			}
+		})
+	}
+}
+
+func TestParseLike(t *testing.T) {
+	item := `<li><h3><strong><a href="/users/10002">A</a></strong></h3><div class="post-group-name">@G</div><p class="post-status-string"><a href="/statuses/1000001">Post</a><a class="exact-time" id="post-1000001-happened-at-exact-time">Sat, 20 Mar 2021 07:15:00 +0100</a></p>%s</li>`
+
+	cases := []struct {
+		name string
+		link string
+		want likeState
+	}{
+		{name: "like", link: `<a class="like-link" id="like-status-1000001" href="/likes">Like</a>`, want: likeNotLiked},
+		{name: "unlike", link: `<a class="like-link" id="like-status-1000001" href="/likes">Unlike</a>`, want: likeLiked},
+		{name: "liked class", link: `<a class="like-link liked" id="like-status-1000001" href="/likes">Gilla</a>`, want: likeLiked},
+		{name: "other language", link: `<a class="like-link" id="like-status-1000001" href="/likes">Gilla</a>`, want: likeUnknown},
+		{name: "other post", link: `<a class="like-link" id="like-status-1000002" href="/likes">Like</a>`, want: likeUnknown},
+		{name: "no link", want: likeUnknown},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			page, errs, err := parseFeed(strings.NewReader(fmt.Sprintf(item, c.link)), "group", "created-at", "all")
+			if err != nil || len(errs) != 0 || len(page.posts) != 1 {
+				t.Fatalf("expected 1 post but got %d, %v and %v", len(page.posts), errs, err)
+			}
+			if got := page.posts[0].likeState; got != c.want {
+				t.Errorf("expected like state %d but got %d", c.want, got)
+			}
+		})
+	}
+}
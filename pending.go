@@ -0,0 +1,196 @@
+package weplus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/comprehend/types"
+)
+
+// Deferred actions that haven't been done within pendingTTL are dropped.
+const pendingTTL = 7 * 24 * time.Hour
+
+// pending is an action that was decided but deferred or scheduled to be sent later.
+// Deferred is when it was decided and Due when it's sent at the earliest. Unliked is
+// if the post was known not to be liked by the user when it was decided.
+type pending struct {
+	PostID   string     `json:"postId"`
+	Feed     string     `json:"feed"`
+	UserID   string     `json:"userId"`
+	Name     string     `json:"name"`
+	Like     bool       `json:"like"`
+	Unliked  bool       `json:"unliked,omitempty"`
+	Comments []string   `json:"comments,omitempty"`
+	Rule     string     `json:"rule,omitempty"`
+	Deferred time.Time  `json:"deferred"`
+	Due      *time.Time `json:"due,omitempty"`
+}
+
+func newPending(act *action, userID string, now time.Time, due time.Time) *pending {
+	p := &pending{PostID: act.PostID, Feed: act.Feed, UserID: userID, Name: act.Name, Like: act.Like, Unliked: act.likeState == likeNotLiked, Comments: act.Comments, Deferred: now}
+	if act.rule != nil {
+		p.Rule = act.rule.key()
+	}
+	if !due.IsZero() {
+		p.Due = &due
+	}
+
+	return p
+}
+
+// savedLike is the like state of a saved post or action. Only if it wasn't liked is
+// saved, since that's the only state it's liked in.
+func savedLike(unliked bool) likeState {
+	if unliked {
+		return likeNotLiked
+	}
+	return likeUnknown
+}
+
+// queue adds the action to the pending actions to be sent when it's due.
+func (d *data) queue(p *pending) {
+	d.Pending = append(d.Pending, p)
+}
+
+// processPending sends the pending actions from earlier runs that are due and fit in
+// the budget, oldest first.
+func (cfg *cfg) processPending(data *data, inp *Input) error {
+	if inp.MarkAsSeen {
+		return nil
+	}
+
+	now := time.Now()
+	all, left := data.Pending, []*pending{}
+
+	for i, p := range all {
+		if cfg.deadline() {
+			cfg.abort()
+			data.Pending = append(left, all[i:]...)
+			return nil
+		}
+
+		if now.Sub(p.Deferred) > pendingTTL {
+			cfg.warn("dropping %s post %s deferred at %s, it's older than %s", p.Feed, p.PostID, p.Deferred.Format(time.RFC3339), pendingTTL)
+			continue
+		}
+
+		if (p.Due != nil && p.Due.After(now)) || !cfg.schedule.active(now) {
+			left = append(left, p)
+			continue
+		}
+
+		act := &action{PostID: p.PostID, Feed: p.Feed, Name: p.Name, Like: p.Like, Comments: p.Comments, Resumed: true, likeState: savedLike(p.Unliked), retry: true}
+		if !cfg.budget.fits(act) {
+			act.Deferred = true
+			act.skip("over budget")
+			cfg.act(act, inp)
+			left = append(left, p)
+			continue
+		}
+
+		// The action is no longer pending when the state is saved before it's sent.
+		data.Pending = append(append([]*pending{}, left...), all[i+1:]...)
+		if err := cfg.send(act, p, data, inp); err != nil {
+			return fmt.Errorf("couldn't do deferred action for %s post %s. %w", p.Feed, p.PostID, err)
+		}
+	}
+
+	data.Pending = left
+	return nil
+}
+
+// savedPost is a post that was fetched but not processed before the deadline.
+type savedPost struct {
+	Feed             string    `json:"feed"`
+	Group            bool      `json:"group"`
+	Exercise         bool      `json:"exercise"`
+	Date             time.Time `json:"date"`
+	PostID           string    `json:"postId"`
+	UserID           string    `json:"userId"`
+	Name             string    `json:"name"`
+	GroupName        string    `json:"groupName"`
+	TrainingDuration string    `json:"trainingDuration"`
+	TrainingType     string    `json:"trainingType"`
+	Text             string    `json:"text"`
+	Unliked          bool      `json:"unliked,omitempty"`
+}
+
+// unprocessed saves the posts of the feed that aren't seen, so the next run processes
+// them first if this run doesn't get to them. They are removed when they are processed.
+func (d *data) unprocessed(f *feed, posts []*post) {
+	for _, p := range posts {
+		if f.seen.has(p.postID) {
+			continue
+		}
+
+		d.Unprocessed = append(d.Unprocessed, &savedPost{
+			Feed:             f.name,
+			Group:            p.group,
+			Exercise:         p.exercise,
+			Date:             p.date,
+			PostID:           p.postID,
+			UserID:           p.userID,
+			Name:             p.name,
+			GroupName:        p.groupName,
+			TrainingDuration: p.trainingDuration,
+			TrainingType:     p.trainingType,
+			Text:             p.text,
+			Unliked:          p.likeState == likeNotLiked,
+		})
+	}
+}
+
+// processed removes the post from the unprocessed posts of the feed.
+func (d *data) processed(feed string, id string) {
+	left := []*savedPost{}
+	for _, p := range d.Unprocessed {
+		if p.Feed != feed || p.PostID != id {
+			left = append(left, p)
+		}
+	}
+	d.Unprocessed = left
+}
+
+// resume returns the unprocessed posts of the feed from an earlier run before the
+// posts, and removes them from the unprocessed posts and seen.
+func (d *data) resume(feed string, posts []*post, seen seenPosts) []*post {
+	res := []*post{}
+	ids := map[string]bool{}
+	left := []*savedPost{}
+	for _, p := range d.Unprocessed {
+		if p.Feed != feed {
+			left = append(left, p)
+			continue
+		}
+
+		res = append(res, &post{
+			group:            p.Group,
+			exercise:         p.Exercise,
+			date:             p.Date,
+			postID:           p.PostID,
+			userID:           p.UserID,
+			name:             p.Name,
+			groupName:        p.GroupName,
+			trainingDuration: p.TrainingDuration,
+			trainingType:     p.TrainingType,
+			text:             p.Text,
+			likeState:        savedLike(p.Unliked),
+			sentiment:        types.SentimentTypeNeutral,
+			resumed:          true,
+		})
+		ids[p.PostID] = true
+		delete(seen, p.PostID)
+	}
+	d.Unprocessed = left
+	if len(res) == 0 {
+		return posts
+	}
+
+	for _, p := range posts {
+		if !ids[p.postID] {
+			res = append(res, p)
+		}
+	}
+
+	return res
+}
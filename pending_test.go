@@ -0,0 +1,192 @@
+package weplus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunDeadline(t *testing.T) {
+	fw := newFakeWeplus(t)
+	fw.add("group",
+		&fakePost{ID: "2000001", UserID: "10002", Name: "Cecilia Carlsson", Group: "@Hawks", Duration: 30, Kind: "Yoga", Date: date(t, "Sat, 20 Mar 2021 06:00:00 +0100")},
+		&fakePost{ID: "2000002", UserID: "10003", Name: "David Dahl", Group: "@Hawks", Duration: 45, Kind: "Löpning", Date: date(t, "Sat, 20 Mar 2021 07:00:00 +0100")},
+		&fakePost{ID: "2000003", UserID: "10004", Name: "Frida Falk", Group: "@Hawks", Duration: 60, Kind: "Cykling", Date: date(t, "Sat, 20 Mar 2021 08:00:00 +0100")},
+	)
+
+	store := fw.newStore("| type == group | Go {{first .Name}}!")
+
+	// Less than 30 seconds left aborts the run before any post is processed.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cfg := fw.newCfg(t, store)
+	cfg.ctx = ctx
+	report, err := cfg.run(&Input{Email: fw.email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Aborted || len(report.Actions) != 0 {
+		t.Fatalf("expected the run to be aborted before any action but got %+v", report.Counts)
+	}
+
+	raw, _ := store.download("erik@example.com.json")
+	d := &data{}
+	if err := json.Unmarshal(raw, d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Group) != 0 || len(d.Unprocessed) != 3 || d.Unprocessed[0].Name != "Frida Falk" {
+		t.Fatalf("expected the posts to be unprocessed and not seen but got %s", raw)
+	}
+
+	// A new post is added and the unprocessed ones are processed first, even though
+	// the feed stops paging at the first seen post.
+	fw.add("group", &fakePost{ID: "2000004", UserID: "10005", Name: "Gustav Gran", Group: "@Hawks", Duration: 20, Kind: "Yoga", Date: date(t, "Sat, 20 Mar 2021 09:00:00 +0100")})
+	report = fw.run(t, store, &Input{Email: fw.email})
+	if report.Aborted || report.Counts.Liked != 4 || !report.Actions[0].Resumed || report.Actions[3].Resumed {
+		t.Fatalf("expected the unprocessed posts to be resumed first but got %+v", report.Counts)
+	}
+
+	want := []string{"2000003", "2000002", "2000001", "2000004"}
+	if got := fw.liked(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected likes %q but got %q", want, got)
+	}
+
+	// Nothing is done twice.
+	if report := fw.run(t, store, &Input{Email: fw.email}); report.Counts.Liked != 0 || report.Counts.Commented != 0 {
+		t.Errorf("expected nothing to be done again but got %+v", report.Counts)
+	}
+	raw, _ = store.download("erik@example.com.json")
+	d = &data{}
+	if err := json.Unmarshal(raw, d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Group) != 4 || len(d.Unprocessed) != 0 {
+		t.Errorf("expected 4 seen posts and none unprocessed but got %s", raw)
+	}
+}
+
+func TestPendingExpires(t *testing.T) {
+	cfg := &cfg{ctx: context.Background()}
+	d := &data{Pending: []*pending{
+		{PostID: "2000001", Feed: "group", Like: true, Deferred: time.Now().Add(-8 * 24 * time.Hour)},
+	}}
+
+	if err := cfg.processPending(d, &Input{DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Pending) != 0 {
+		t.Errorf("expected the expired action to be dropped but got %d pending", len(d.Pending))
+	}
+}
+
+func TestRunCheckpoint(t *testing.T) {
+	fw := newFakeWeplus(t)
+	fw.add("group",
+		&fakePost{ID: "2000001", UserID: "10002", Name: "Cecilia Carlsson", Group: "@Hawks", Duration: 30, Kind: "Yoga", Date: date(t, "Sat, 20 Mar 2021 06:00:00 +0100")},
+		&fakePost{ID: "2000002", UserID: "10003", Name: "David Dahl", Group: "@Hawks", Duration: 45, Kind: "Löpning", Date: date(t, "Sat, 20 Mar 2021 07:00:00 +0100")},
+		&fakePost{ID: "2000003", UserID: "10004", Name: "Frida Falk", Group: "@Hawks", Duration: 60, Kind: "Cykling", Date: date(t, "Sat, 20 Mar 2021 08:00:00 +0100")},
+	)
+	fw.failLike = "2000002"
+
+	store := fw.newStore("| type == group | Nice")
+
+	run := func() (*Report, error) {
+		return fw.newCfg(t, store).run(&Input{Email: fw.email})
+	}
+
+	// The run fails on the second post but what was done is saved, with the failed
+	// action in flight and the rest of the posts unprocessed.
+	if _, err := run(); err == nil {
+		t.Fatal("expected the run to fail")
+	}
+
+	raw, _ := store.download("erik@example.com.json")
+	d := &data{}
+	if err := json.Unmarshal(raw, d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Group) != 2 || d.InFlight == nil || d.InFlight.PostID != "2000002" || len(d.Unprocessed) != 1 {
+		t.Fatalf("expected 2 seen posts, 2000002 in flight and 1 unprocessed but got %s", raw)
+	}
+
+	// The next run retries the action in flight without liking the post again, since
+	// it might have been liked, and then processes the rest.
+	fw.failLike = ""
+	report, err := run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Counts.Liked != 1 || report.Counts.Commented != 2 || report.Counts.Errors != 1 {
+		t.Errorf("expected 1 liked, 2 commented and 1 error but got %+v", report.Counts)
+	}
+	if act := report.Actions[0]; act.PostID != "2000002" || !act.Resumed || !reflect.DeepEqual(act.Reasons, []string{"like state unknown"}) {
+		t.Errorf("expected 2000002 to be retried without a like but got %+v", act)
+	}
+
+	want := []string{"2000003", "2000002", "2000001"}
+	if got := fw.liked(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected likes %q but got %q", want, got)
+	}
+	if got := fw.commented(); len(got) != 3 {
+		t.Errorf("expected 3 comments but got %d", len(got))
+	}
+
+	raw, _ = store.download("erik@example.com.json")
+	d = &data{}
+	if err := json.Unmarshal(raw, d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Group) != 3 || d.InFlight != nil || len(d.Unprocessed) != 0 {
+		t.Errorf("expected 3 seen posts and none in flight or unprocessed but got %s", raw)
+	}
+}
+
+// killedStore stops saving the state after the first save, as if the run was killed
+// right after it.
+type killedStore struct {
+	*memStore
+	saved bool
+}
+
+func (k *killedStore) saveIf(file string, raw []byte, version string) (string, error) {
+	if strings.HasSuffix(file, ".json") {
+		if k.saved {
+			return "", fmt.Errorf("killed")
+		}
+		k.saved = true
+	}
+
+	return k.memStore.saveIf(file, raw, version)
+}
+
+func TestRunKilled(t *testing.T) {
+	fw := newFakeWeplus(t)
+	for i := 1; i <= 5; i++ {
+		fw.add("group", &fakePost{ID: fmt.Sprintf("200000%d", i), UserID: "10002", Name: "Cecilia Carlsson", Group: "@Hawks", Duration: 30, Kind: "Yoga", Date: time.Now().Add(-time.Duration(10-i) * time.Hour)})
+	}
+
+	mem := fw.newStore("| type == group | Nice")
+
+	// The state is left as it was saved before the first like.
+	if _, err := fw.newCfg(t, &killedStore{memStore: mem}).run(&Input{Email: fw.email}); err == nil {
+		t.Fatal("expected the run to fail")
+	}
+	if got := fw.liked(); len(got) != 1 || got[0] != "2000005" {
+		t.Fatalf("expected only 2000005 to be liked but got %q", got)
+	}
+
+	// The next run retries the first post and processes the older ones, even though
+	// they are on later pages of the feed.
+	fw.run(t, mem, &Input{Email: fw.email})
+	want := []string{"2000005", "2000004", "2000003", "2000002", "2000001"}
+	if got := fw.liked(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected likes %q but got %q", want, got)
+	}
+	if got := fw.commented(); len(got) != 5 {
+		t.Errorf("expected 5 comments but got %d", len(got))
+	}
+}
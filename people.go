@@ -0,0 +1,136 @@
+package weplus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const peopleVersion = 1
+
+// The people files are tried in this order.
+var peopleExts = []string{"yaml", "yml", "json"}
+
+// people is who to like and comment, written as yaml or json. The keys are user ids.
+type people struct {
+	Version int                `yaml:"version"`
+	Allow   []string           `yaml:"allow"`
+	Deny    []string           `yaml:"deny"`
+	People  map[string]*person `yaml:"people"`
+}
+
+// person overrides the ratios of the feeds for a user.
+type person struct {
+	Name         string   `yaml:"name"`
+	LikeRatio    *float64 `yaml:"likeRatio"`
+	CommentRatio *float64 `yaml:"commentRatio"`
+}
+
+// ValidatePeople returns an error for every problem in a people file.
+func ValidatePeople(raw []byte) error {
+	_, err := loadPeople(raw)
+	return err
+}
+
+func loadPeople(raw []byte) (*people, error) {
+	p := &people{}
+
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("couldn't parse people file. %w", err)
+	}
+
+	errs := []string{}
+	switch {
+	case p.Version == 0:
+		errs = append(errs, "version is required")
+	case p.Version != peopleVersion:
+		errs = append(errs, fmt.Sprintf("unsupported version %d, expected %d", p.Version, peopleVersion))
+	}
+
+	for _, id := range append(append([]string{}, p.Allow...), p.Deny...) {
+		if !isNumber(id) {
+			errs = append(errs, fmt.Sprintf("%q is not a user id", id))
+		}
+	}
+
+	// Sort the ids so the errors are always in the same order.
+	ids := []string{}
+	for id := range p.People {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if !isNumber(id) {
+			errs = append(errs, fmt.Sprintf("%q is not a user id", id))
+		}
+
+		per := p.People[id]
+		if per == nil {
+			errs = append(errs, fmt.Sprintf("person %s: likeRatio or commentRatio is required", id))
+			continue
+		}
+		for name, ratio := range map[string]*float64{"likeRatio": per.LikeRatio, "commentRatio": per.CommentRatio} {
+			if ratio != nil && (*ratio < 0 || *ratio > 1) {
+				errs = append(errs, fmt.Sprintf("person %s: %s must be between 0 and 1", id, name))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+	return p, nil
+}
+
+// skip returns why the posts of the user shouldn't be liked or commented, or an empty string if they should.
+func (p *people) skip(userID string) string {
+	if p == nil {
+		return ""
+	}
+
+	for _, id := range p.Deny {
+		if id == userID {
+			return "user is in the deny list"
+		}
+	}
+
+	if len(p.Allow) == 0 {
+		return ""
+	}
+	for _, id := range p.Allow {
+		if id == userID {
+			return ""
+		}
+	}
+
+	return "user isn't in the allow list"
+}
+
+// apply returns the feed with the ratios of the user, if they are overridden.
+func (p *people) apply(userID string, f *feed) *feed {
+	if p == nil || p.People[userID] == nil {
+		return f
+	}
+
+	per := p.People[userID]
+	res := *f
+	if per.LikeRatio != nil {
+		res.likeRatio = *per.LikeRatio
+		res.likeRatioName = fmt.Sprintf("likeRatio of user %s", userID)
+	}
+	if per.CommentRatio != nil {
+		res.commentRatio = *per.CommentRatio
+		res.commentRatioName = fmt.Sprintf("commentRatio of user %s", userID)
+	}
+
+	return &res
+}
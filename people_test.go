@@ -0,0 +1,157 @@
+package weplus
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadPeople(t *testing.T) {
+	one, zero := 1.0, 0.0
+	want := &people{
+		Version: 1,
+		Allow:   []string{"10002", "10003"},
+		Deny:    []string{"10004"},
+		People: map[string]*person{
+			"10002": {Name: "Manager", LikeRatio: &one},
+			"10003": {CommentRatio: &zero},
+		},
+	}
+
+	cases := map[string]string{
+		"yaml": `version: 1
+allow: ["10002", "10003"]
+deny:
+  - "10004"
+people:
+  "10002":
+    name: Manager
+    likeRatio: 1
+  "10003": {commentRatio: 0}
+`,
+		"json": `{"version": 1, "allow": ["10002", "10003"], "deny": ["10004"], "people": {"10002": {"name": "Manager", "likeRatio": 1}, "10003": {"commentRatio": 0}}}`,
+	}
+
+	for name, raw := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := loadPeople([]byte(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %+v but got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestLoadPeopleErrors(t *testing.T) {
+	cases := map[string]string{
+		"":                          "version is required",
+		"version: 2":                "unsupported version 2, expected 1",
+		"version: 1\nalow: [10002]": "line 2: field alow not found",
+		"version: 1\ndeny: [Big Boss]\npeople:\n  boss: {likeRatio: 1}": `"Big Boss" is not a user id; "boss" is not a user id`,
+		"version: 1\npeople:\n  \"10002\": {likeRatio: 2}":              "person 10002: likeRatio must be between 0 and 1",
+		"version: 1\npeople:\n  \"10002\":":                             "person 10002: likeRatio or commentRatio is required",
+		"version: 1\npeople:\n  \"10002\": {likeRatio: 1, comment: 0}":  "field comment not found",
+	}
+
+	for raw, want := range cases {
+		if _, err := loadPeople([]byte(raw)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q to fail with %q but got %v", raw, want, err)
+		}
+	}
+}
+
+func TestPeopleSkip(t *testing.T) {
+	var none *people
+	if reason := none.skip("10002"); reason != "" {
+		t.Errorf("expected no people file to skip nothing but got %q", reason)
+	}
+
+	p := &people{Deny: []string{"10004"}}
+	if reason := p.skip("10004"); reason != "user is in the deny list" {
+		t.Errorf("expected denied user to be skipped but got %q", reason)
+	}
+	if reason := p.skip("10002"); reason != "" {
+		t.Errorf("expected user to not be skipped without an allow list but got %q", reason)
+	}
+
+	p.Allow = []string{"10002", "10004"}
+	for userID, want := range map[string]string{"10002": "", "10003": "user isn't in the allow list", "10004": "user is in the deny list"} {
+		if reason := p.skip(userID); reason != want {
+			t.Errorf("expected user %s to be skipped with %q but got %q", userID, want, reason)
+		}
+	}
+}
+
+func TestRunPeople(t *testing.T) {
+	fw := newFakeWeplus(t)
+	fw.add("group",
+		&fakePost{ID: "2000001", UserID: "10002", Name: "Cecilia Carlsson", Group: "@Hawks", Duration: 30, Kind: "Yoga", Date: date(t, "Sat, 20 Mar 2021 06:00:00 +0100")},
+		&fakePost{ID: "2000002", UserID: "10003", Name: "David Dahl", Group: "@Hawks", Duration: 45, Kind: "Löpning", Date: date(t, "Sat, 20 Mar 2021 07:00:00 +0100")},
+	)
+	fw.add("company",
+		&fakePost{ID: "3000001", UserID: "10006", Name: "Hanna Holm", Group: "@Competitors", Duration: 60, Kind: "Promenad", Date: date(t, "Sat, 20 Mar 2021 08:30:00 +0100")},
+		&fakePost{ID: "3000002", UserID: "10007", Name: "Ida Ivarsson", Group: "@Competitors", Duration: 25, Kind: "Yoga", Date: date(t, "Sat, 20 Mar 2021 09:30:00 +0100")},
+	)
+
+	store := newMemStore()
+	store.save("erik@example.com.comments.txt", []byte("| type == group | Go {{Name}}!\n|| Nice"))
+	store.save("erik@example.com.people.yaml", []byte(`version: 1
+deny: ["10003"]
+people:
+  # Always like and comment the manager, never comment Cecilia.
+  "10007": {likeRatio: 1, commentRatio: 1}
+  "10002": {commentRatio: 0}
+`))
+	state := []byte(`{"group":[],"company":[]}`)
+	store.save("erik@example.com.json", state)
+
+	zero := 0.0
+	report := fw.run(t, store, &Input{Email: fw.email, LikeRatio: &zero, CommentRatio: &zero})
+
+	got := map[string][]string{}
+	for _, act := range report.Actions {
+		got[act.PostID] = act.Reasons
+	}
+	want := map[string][]string{
+		"2000001": {"comment not selected by commentRatio of user 10002"},
+		"2000002": {"user is in the deny list"},
+		"3000001": {"comment not selected by commentRatio", "like not selected by likeRatio"},
+		"3000002": nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected reasons %q but got %q", want, got)
+	}
+
+	if want := []string{"2000001", "3000002"}; !reflect.DeepEqual(fw.liked(), want) {
+		t.Errorf("expected likes %q but got %q", want, fw.liked())
+	}
+	if want := []*fakeComment{{postID: "3000002", body: "Nice"}}; !reflect.DeepEqual(fw.commented(), want) {
+		t.Errorf("unexpected comments %+v", fw.commented())
+	}
+
+	// Posts that are skipped for the people are still seen.
+	raw, _ := store.download("erik@example.com.json")
+	d := &data{}
+	if err := json.Unmarshal(raw, d); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2000001", "2000002"}; !reflect.DeepEqual(seenIDs(d.Group), want) {
+		t.Errorf("expected the group posts to be seen but got %s", raw)
+	}
+}
+
+func TestExamplePeople(t *testing.T) {
+	raw, err := os.ReadFile("setter/people.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidatePeople(raw); err != nil {
+		t.Error(err)
+	}
+}
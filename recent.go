@@ -0,0 +1,79 @@
+package weplus
+
+import (
+	"strings"
+	"time"
+)
+
+// recentComment is a comment that was recently made to a user.
+type recentComment struct {
+	Text string    `json:"text"`
+	Date time.Time `json:"date"`
+}
+
+// key is what identifies the comments of a rule in the recent comments.
+func (c *comment) key() string {
+	return strings.Join(c.comments, "\n")
+}
+
+// remember adds the comments with the key as recently made to the user.
+func (d *data) remember(userID string, key string, now time.Time) {
+	if userID == "" || key == "" {
+		return
+	}
+
+	if d.Recent == nil {
+		d.Recent = map[string][]*recentComment{}
+	}
+	d.Recent[userID] = append(d.Recent[userID], &recentComment{Text: key, Date: now})
+}
+
+// recent returns the comments made to the user since since.
+func (d *data) recent(userID string, since time.Time) []*recentComment {
+	recent := []*recentComment{}
+	for _, r := range d.Recent[userID] {
+		if r.Date.After(since) {
+			recent = append(recent, r)
+		}
+	}
+
+	return recent
+}
+
+// prune removes the comments made before since.
+func (d *data) prune(since time.Time) {
+	for userID := range d.Recent {
+		recent := d.recent(userID, since)
+		if len(recent) == 0 {
+			delete(d.Recent, userID)
+			continue
+		}
+		d.Recent[userID] = recent
+	}
+}
+
+// leastRecent returns the comments that haven't been made recently. If all of them
+// have it returns the least recently made ones.
+func leastRecent(comments []*comment, recent []*recentComment) []*comment {
+	used := map[string]time.Time{}
+	for _, r := range recent {
+		if r.Date.After(used[r.Text]) {
+			used[r.Text] = r.Date
+		}
+	}
+
+	// Comments that haven't been made have the zero time and are always the least recent.
+	least := []*comment{}
+	oldest := time.Time{}
+	for _, c := range comments {
+		date := used[c.key()]
+		switch {
+		case len(least) == 0 || date.Before(oldest):
+			least, oldest = []*comment{c}, date
+		case date.Equal(oldest):
+			least = append(least, c)
+		}
+	}
+
+	return least
+}
@@ -0,0 +1,99 @@
+package weplus
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLeastRecent(t *testing.T) {
+	comments := []*comment{
+		{name: "a", comments: []string{"a"}},
+		{name: "b", comments: []string{"b", "b2"}},
+		{name: "c", comments: []string{"c"}},
+	}
+	now := time.Date(2021, 3, 20, 7, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name   string
+		recent []*recentComment
+		want   []string
+	}{
+		{name: "none made", want: []string{"a", "b", "c"}},
+		{name: "some made", recent: []*recentComment{{Text: "a", Date: now}, {Text: "b\nb2", Date: now}}, want: []string{"c"}},
+		{
+			name: "all made",
+			recent: []*recentComment{
+				{Text: "a", Date: now.Add(-time.Hour)},
+				{Text: "b\nb2", Date: now.Add(-3 * time.Hour)},
+				{Text: "c", Date: now.Add(-2 * time.Hour)},
+				// Only the last time a comment was made matters.
+				{Text: "b\nb2", Date: now.Add(-30 * time.Minute)},
+			},
+			want: []string{"c"},
+		},
+		{name: "all made at once", recent: []*recentComment{{Text: "a", Date: now}, {Text: "b\nb2", Date: now}, {Text: "c", Date: now}}, want: []string{"a", "b", "c"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := []string{}
+			for _, comment := range leastRecent(comments, c.recent) {
+				got = append(got, comment.name)
+			}
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected %q but got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestRecent(t *testing.T) {
+	now := time.Date(2021, 3, 20, 7, 0, 0, 0, time.UTC)
+	rule := &comment{comments: []string{"Nice!"}}
+
+	d := &data{}
+	d.remember("10002", rule.key(), now.Add(-8*24*time.Hour))
+	d.remember("10002", rule.key(), now.Add(-time.Hour))
+	d.remember("10003", rule.key(), now.Add(-10*24*time.Hour))
+	d.remember("", rule.key(), now)
+
+	week := now.Add(-7 * 24 * time.Hour)
+	if got := d.recent("10002", week); len(got) != 1 || !got[0].Date.Equal(now.Add(-time.Hour)) {
+		t.Errorf("expected one recent comment in the last week but got %d", len(got))
+	}
+
+	d.prune(week)
+	if _, ok := d.Recent["10003"]; ok || len(d.Recent) != 1 || len(d.Recent["10002"]) != 1 {
+		t.Errorf("expected only the comments from the last week to be kept but got %+v", d.Recent)
+	}
+}
+
+func TestRandomAvoidsRecent(t *testing.T) {
+	comments, err := loadComments([]byte("|| Nice!\n|| Good job!\n|| Wow\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := &post{userID: "10002", trainingType: "Löpning", trainingDuration: "30", exercise: true}
+	now := time.Now()
+
+	// Every comment is made once before any is repeated, and then the least recent one.
+	d := &data{}
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		rule := random(rand.New(rand.NewSource(1)), comments, run, now, d.recent(run.userID, now.Add(-time.Hour)))
+		if seen[rule.key()] {
+			t.Fatalf("expected %q to not be repeated", rule.key())
+		}
+		seen[rule.key()] = true
+		d.remember(run.userID, rule.key(), now.Add(time.Duration(i)*time.Minute))
+	}
+
+	first := d.Recent[run.userID][0].Text
+	if rule := random(rand.New(rand.NewSource(1)), comments, run, now, d.recent(run.userID, now.Add(-time.Hour))); rule.key() != first {
+		t.Errorf("expected the least recent comment %q but got %q", first, rule.key())
+	}
+}
@@ -0,0 +1,215 @@
+package weplus
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Report is the result of a run.
+type Report struct {
+	Email      string    `json:"email"`
+	DryRun     bool      `json:"dryRun"`
+	MarkAsSeen bool      `json:"markAsSeen"`
+	Aborted    bool      `json:"aborted"`
+	Locked     bool      `json:"locked,omitempty"`
+	Seed       int64     `json:"seed"`
+	Message    string    `json:"message"`
+	Counts     counts    `json:"counts"`
+	Actions    []*action `json:"actions"`
+	Errors     []string  `json:"errors"`
+}
+
+type counts struct {
+	Posts     int `json:"posts"`
+	Liked     int `json:"liked"`
+	Commented int `json:"commented"`
+	Skipped   int `json:"skipped"`
+	Deferred  int `json:"deferred"`
+	Scheduled int `json:"scheduled"`
+	Errors    int `json:"errors"`
+}
+
+// action is what was decided and done for a single post. Like and Comments are what
+// was decided and Liked and Commented if it was actually done. Deferred actions are
+// over budget and Scheduled ones not due yet, both are sent in a later run. Resumed
+// ones were deferred or scheduled in an earlier run.
+type action struct {
+	PostID    string       `json:"postId"`
+	Feed      string       `json:"feed"`
+	Name      string       `json:"name"`
+	Sentiment string       `json:"sentiment,omitempty"`
+	Rule      *matchedRule `json:"rule,omitempty"`
+	Like      bool         `json:"like"`
+	Comments  []string     `json:"comments,omitempty"`
+	Liked     bool         `json:"liked"`
+	Commented bool         `json:"commented"`
+	Skipped   bool         `json:"skipped"`
+	Deferred  bool         `json:"deferred,omitempty"`
+	Scheduled *time.Time   `json:"scheduled,omitempty"`
+	Resumed   bool         `json:"resumed,omitempty"`
+	Reasons   []string     `json:"reasons,omitempty"`
+
+	rule      *comment
+	likeState likeState
+	status    *status
+	// retry is if the action was decided by an earlier run, and might be partly done.
+	retry bool
+}
+
+type matchedRule struct {
+	Name       string `json:"name"`
+	Line       int    `json:"line"`
+	Expression string `json:"expression"`
+	Weight     int    `json:"weight"`
+}
+
+func newReport(inp *Input) *Report {
+	return &Report{
+		Email:      inp.Email,
+		DryRun:     inp.DryRun,
+		MarkAsSeen: inp.MarkAsSeen,
+		Actions:    []*action{},
+		Errors:     []string{},
+	}
+}
+
+func newAction(post *post, feed string) *action {
+	return &action{PostID: post.postID, Feed: feed, Name: post.name}
+}
+
+func (act *action) skip(reason string) {
+	act.Reasons = append(act.Reasons, reason)
+}
+
+// act likes and comments the post as decided, unless it's a dry run. Likes and
+// comments that the post already has from the user aren't made again.
+func (cfg *cfg) act(act *action, inp *Input) error {
+	if cfg.report != nil {
+		cfg.report.Actions = append(cfg.report.Actions, act)
+	}
+
+	if inp.DryRun || act.Deferred || act.Scheduled != nil || (!act.Like && len(act.Comments) == 0) {
+		return nil
+	}
+
+	st := act.status
+	if st == nil {
+		var err error
+		if st, err = cfg.getStatus(act.PostID); st == nil {
+			return fmt.Errorf("couldn't get status of %s post %s. %w", act.Feed, act.PostID, err)
+		}
+	}
+
+	if act.Like {
+		// Liking a liked post unlikes it, so it's only liked if it's known not to be.
+		switch act.likeState {
+		case likeUnknown:
+			act.skip("like state unknown")
+		case likeLiked:
+			act.skip("already liked")
+		default:
+			if err := cfg.like(act.PostID); err != nil {
+				return err
+			}
+			act.likeState = likeLiked
+			act.Liked = true
+			fmt.Printf("liking %s post: %s for %s\n", act.Feed, act.PostID, inp.Email)
+		}
+	}
+
+	// A lost state or another run makes another comment than the one already made, so
+	// new comments are only made if the user hasn't commented the post. Retries skip
+	// only the comments that were already made.
+	comments := act.Comments
+	if !act.retry && len(st.comments) > 0 && len(comments) > 0 {
+		act.skip("already commented")
+		comments = nil
+	}
+
+	for _, comment := range comments {
+		if seen(comment, st.comments) {
+			act.skip(fmt.Sprintf("already commented %q", comment))
+			continue
+		}
+		if err := cfg.comment(act.PostID, comment); err != nil {
+			return err
+		}
+		st.comments = append(st.comments, comment)
+		act.Commented = true
+		fmt.Printf("commenting '%s' on %s post: %s for %s\n", comment, act.Feed, act.PostID, inp.Email)
+	}
+
+	return nil
+}
+
+// warn logs an error that doesn't stop the run and adds it to the report.
+func (cfg *cfg) warn(format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	fmt.Println(msg)
+
+	if cfg.report != nil {
+		cfg.report.Errors = append(cfg.report.Errors, msg)
+	}
+}
+
+func (cfg *cfg) abort() {
+	fmt.Printf("less then 30 seconds left of deadline. aborting and saving state!\n")
+
+	if cfg.report != nil {
+		cfg.report.Aborted = true
+	}
+}
+
+func (r *Report) finish() *Report {
+	for _, act := range r.Actions {
+		act.Skipped = !act.Liked && !act.Commented
+		if r.DryRun {
+			act.Skipped = !act.Like && len(act.Comments) == 0
+		}
+		if act.Deferred {
+			act.Skipped = false
+			r.Counts.Deferred++
+		}
+		if act.Scheduled != nil {
+			act.Skipped = false
+			r.Counts.Scheduled++
+		}
+
+		r.Counts.Posts++
+		if act.Liked {
+			r.Counts.Liked++
+		}
+		if act.Commented {
+			r.Counts.Commented++
+		}
+		if act.Skipped {
+			r.Counts.Skipped++
+		}
+	}
+	r.Counts.Errors = len(r.Errors)
+
+	switch {
+	case r.Locked:
+		r.Message = "run already in progress, nothing was done"
+	case r.DryRun:
+		r.Message = "dry run, nothing was liked or commented and no state was saved"
+	case r.MarkAsSeen:
+		r.Message = "state saved up until now! you can now run in it normally"
+	case r.Counts.Liked == 0 && r.Counts.Commented == 0:
+		r.Message = "nothing liked or commented since last run!"
+	default:
+		r.Message = fmt.Sprintf("liked %d and commented %d posts", r.Counts.Liked, r.Counts.Commented)
+	}
+	if r.Counts.Deferred > 0 {
+		r.Message = fmt.Sprintf("%s. %d posts over budget were deferred to the next run", r.Message, r.Counts.Deferred)
+	}
+	if r.Counts.Scheduled > 0 {
+		r.Message = fmt.Sprintf("%s. %d posts were scheduled for later runs", r.Message, r.Counts.Scheduled)
+	}
+	if r.Aborted {
+		r.Message = strings.Join([]string{r.Message, "run was aborted due to the deadline, the rest is handled next run"}, ". ")
+	}
+
+	return r
+}
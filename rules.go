@@ -0,0 +1,405 @@
+package weplus
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const rulesVersion = 1
+
+// The strategies for choosing between matching rules. Priority chooses among the ones with
+// the highest weight and weighted with a probability proportional to the weight.
+const (
+	strategyPriority = "priority"
+	strategyWeighted = "weighted"
+)
+
+var (
+	ruleFileRegexp = regexp.MustCompile(`(?m)\A\s*\{|^(version|timezone|strategy|rules)[ ]*:`)
+
+	// The comments files are tried in this order, txt is the legacy format.
+	commentsExts = []string{"yaml", "yml", "json", "txt"}
+)
+
+type comment struct {
+	line      int
+	name      string
+	raw       string
+	weight    int
+	sentiment string
+	match     expr
+	loc       *time.Location
+	strategy  string
+	comments  []string
+	templates []*template.Template
+}
+
+// ruleFile is the versioned rule file format, written as yaml or json.
+type ruleFile struct {
+	Version  int
+	Timezone string
+	Strategy string
+	Rules    []*rule
+
+	timezoneLine int
+	strategyLine int
+}
+
+type rule struct {
+	Name     string
+	Weight   int
+	Match    []string
+	Comments []string
+
+	line         int
+	matchLines   []int
+	commentLines []int
+	// legacy rules are converted from the legacy format, where a rule without comments
+	// matches without commenting.
+	legacy bool
+}
+
+type ruleError struct {
+	line int
+	rule string
+	msg  string
+}
+
+func (e *ruleError) Error() string {
+	if e.rule == "" {
+		return fmt.Sprintf("line %d: %s", e.line, e.msg)
+	}
+	return fmt.Sprintf("line %d: rule %q: %s", e.line, e.rule, e.msg)
+}
+
+type ruleErrors []*ruleError
+
+func (errs ruleErrors) Error() string {
+	strs := []string{}
+	for _, err := range errs {
+		strs = append(strs, err.Error())
+	}
+	return strings.Join(strs, "; ")
+}
+
+func (errs *ruleErrors) add(line int, rule string, format string, a ...interface{}) {
+	*errs = append(*errs, &ruleError{line: line, rule: rule, msg: fmt.Sprintf(format, a...)})
+}
+
+// ValidateComments returns an error with line numbers for every problem in a rule file
+// or legacy comments file.
+func ValidateComments(raw []byte) error {
+	_, err := loadComments(raw)
+	return err
+}
+
+func loadComments(raw []byte) ([]*comment, error) {
+	rf, err := parseRules(raw)
+	errs, ok := err.(ruleErrors)
+	if err != nil && !ok {
+		return nil, err
+	}
+
+	// Compile even if parsing failed so all errors are reported at once.
+	comments, err := rf.compile()
+	if err != nil {
+		errs = append(errs, err.(ruleErrors)...)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return comments, nil
+}
+
+// parseRules parses a rule file. Files that don't start like a rule file are
+// treated as the legacy comments format and converted.
+func parseRules(raw []byte) (*ruleFile, error) {
+	if !ruleFileRegexp.Match(raw) {
+		return convertLegacy(raw)
+	}
+
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(raw, doc); err != nil {
+		return nil, fmt.Errorf("couldn't parse rule file. %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return &ruleFile{}, ruleErrors{{line: 1, msg: "expected a mapping with version and rules"}}
+	}
+	root := doc.Content[0]
+
+	rf := &ruleFile{}
+	errs := ruleErrors{}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, val := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "version":
+			if err := val.Decode(&rf.Version); err != nil {
+				errs.add(val.Line, "", "version must be a number")
+			}
+		case "timezone":
+			rf.Timezone, rf.timezoneLine = val.Value, val.Line
+		case "strategy":
+			rf.Strategy, rf.strategyLine = val.Value, val.Line
+		case "rules":
+			if val.Kind != yaml.SequenceNode {
+				errs.add(val.Line, "", "rules must be a list")
+				continue
+			}
+			for _, n := range val.Content {
+				rf.Rules = append(rf.Rules, decodeRule(n, &errs))
+			}
+		default:
+			errs.add(key.Line, "", "unknown key %q", key.Value)
+		}
+	}
+
+	switch {
+	case rf.Version == 0:
+		errs.add(root.Line, "", "version is required")
+	case rf.Version != rulesVersion:
+		errs.add(root.Line, "", "unsupported version %d, expected %d", rf.Version, rulesVersion)
+	}
+
+	if len(errs) > 0 {
+		return rf, errs
+	}
+	return rf, nil
+}
+
+func decodeRule(n *yaml.Node, errs *ruleErrors) *rule {
+	r := &rule{line: n.Line}
+	if n.Kind != yaml.MappingNode {
+		errs.add(n.Line, "", "rule must be a mapping with name, weight, match and comments")
+		return r
+	}
+
+	// Get the name first so all errors for the rule can refer to it.
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == "name" {
+			r.Name = n.Content[i+1].Value
+		}
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i], n.Content[i+1]
+		switch key.Value {
+		case "name":
+			if val.Kind != yaml.ScalarNode {
+				errs.add(val.Line, r.Name, "name must be a string")
+			}
+		case "weight":
+			if err := val.Decode(&r.Weight); err != nil {
+				errs.add(val.Line, r.Name, "weight must be a whole number")
+			}
+		case "match":
+			r.Match, r.matchLines = decodeStrings(val, r.Name, "match", errs)
+		case "comments":
+			r.Comments, r.commentLines = decodeStrings(val, r.Name, "comments", errs)
+		default:
+			errs.add(key.Line, r.Name, "unknown key %q", key.Value)
+		}
+	}
+
+	return r
+}
+
+// decodeStrings decodes a single string or a list of strings and their lines.
+func decodeStrings(n *yaml.Node, rule string, key string, errs *ruleErrors) ([]string, []int) {
+	nodes := []*yaml.Node{n}
+	if n.Kind == yaml.SequenceNode {
+		nodes = n.Content
+	}
+
+	strs, lines := []string{}, []int{}
+	for _, n := range nodes {
+		if n.Kind != yaml.ScalarNode {
+			errs.add(n.Line, rule, "%s must be a string or a list of strings", key)
+			continue
+		}
+		strs, lines = append(strs, n.Value), append(lines, n.Line)
+	}
+
+	return strs, lines
+}
+
+// joinMatch joins the match expressions with &&, adding parentheses where needed.
+func joinMatch(match []string) string {
+	if len(match) < 2 {
+		return strings.Join(match, "")
+	}
+
+	strs := []string{}
+	for _, m := range match {
+		if strings.Contains(m, "||") {
+			m = fmt.Sprintf("(%s)", m)
+		}
+		strs = append(strs, m)
+	}
+
+	return strings.Join(strs, " && ")
+}
+
+// convertLegacy converts the legacy comments format of rows with
+// "weight | expr && expr | comment | comment" to a rule file.
+func convertLegacy(raw []byte) (*ruleFile, error) {
+	rf := &ruleFile{Version: rulesVersion}
+	errs := ruleErrors{}
+
+	for i, commentPair := range strings.Split(string(raw), "\n") {
+		r := &rule{Name: fmt.Sprintf("line %d", i+1), line: i + 1, legacy: true}
+
+		rawComment := strings.Split(commentPair, "|")
+		// Continue if row doesn't contain valid data.
+		if len(rawComment) < 3 {
+			continue
+		}
+
+		rawWeight := strings.TrimSpace(rawComment[0])
+		if rawWeight != "" {
+			weight, err := strconv.Atoi(rawWeight)
+			if err != nil {
+				errs.add(r.line, "", "couldn't convert weight %s to int", rawWeight)
+				continue
+			}
+			r.Weight = weight
+		}
+
+		// A || in the expression splits it into an empty field, and what follows it would be
+		// used as comments.
+		exprs := strings.TrimSpace(rawComment[1])
+		if exprs != "" && len(rawComment) > 3 && strings.TrimSpace(rawComment[2]) == "" {
+			errs.add(r.line, "", "expressions with || or | can't be used in the legacy format, use the yaml format")
+			continue
+		}
+
+		if exprs != "" {
+			for _, expr := range strings.Split(exprs, "&&") {
+				r.Match = append(r.Match, strings.TrimSpace(expr))
+				r.matchLines = append(r.matchLines, r.line)
+			}
+		}
+
+		for _, str := range rawComment[2:] {
+			if str = strings.TrimSpace(str); str != "" {
+				r.Comments = append(r.Comments, str)
+				r.commentLines = append(r.commentLines, r.line)
+			}
+		}
+
+		rf.Rules = append(rf.Rules, r)
+	}
+
+	if len(errs) > 0 {
+		return rf, errs
+	}
+	return rf, nil
+}
+
+// compile validates the rules and turns them into comments that can be matched against posts.
+func (rf *ruleFile) compile() ([]*comment, error) {
+	comments := []*comment{}
+	errs := ruleErrors{}
+	names := map[string]int{}
+
+	strategy := strategyPriority
+	switch rf.Strategy {
+	case "", strategyPriority:
+	case strategyWeighted:
+		strategy = strategyWeighted
+	default:
+		errs.add(rf.strategyLine, "", "unknown strategy %q, expected %s or %s", rf.Strategy, strategyPriority, strategyWeighted)
+	}
+
+	// Without a timezone dates are compared in UTC and the time variable is in the timezone of the post.
+	var loc *time.Location
+	if rf.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(rf.Timezone); err != nil {
+			errs.add(rf.timezoneLine, "", "unknown timezone %q", rf.Timezone)
+		}
+	}
+
+	for _, r := range rf.Rules {
+		switch prev, ok := names[r.Name]; {
+		case r.Name == "":
+			errs.add(r.line, "", "name is required")
+		case ok:
+			errs.add(r.line, r.Name, "name is already used by the rule on line %d", prev)
+		default:
+			names[r.Name] = r.line
+		}
+
+		comment := &comment{line: r.line, name: r.Name, raw: joinMatch(r.Match), weight: r.Weight, loc: loc, strategy: strategy}
+		if strategy == strategyWeighted && r.Weight < 0 {
+			errs.add(r.line, r.Name, "weight can't be negative with the %s strategy", strategyWeighted)
+		}
+
+		conds := []expr{}
+		for i, match := range r.Match {
+			e, err := parseExpr(match)
+			if err != nil {
+				errs.add(r.matchLines[i], r.Name, "invalid expression %q. %v", match, err)
+				continue
+			}
+
+			for _, cond := range conjuncts(e) {
+				// Special case for sentiment, a top level sentiment == neg marks the comment for
+				// negative posts. Other sentiments are matched like any other expression.
+				if expr, ok := cond.(*expression); ok && expr.is("sentiment", "==", "neg") {
+					comment.sentiment = expr.value
+					continue
+				}
+				conds = append(conds, cond)
+			}
+		}
+
+		for _, cond := range conds {
+			if comment.match == nil {
+				comment.match = cond
+				continue
+			}
+			comment.match = &andExpr{left: comment.match, right: cond}
+		}
+
+		for i, str := range r.Comments {
+			if strings.TrimSpace(str) == "" {
+				errs.add(r.commentLines[i], r.Name, "comments can't be empty")
+				continue
+			}
+
+			tmpl, err := parseTemplate(strings.TrimSpace(str))
+			if err != nil {
+				errs.add(r.commentLines[i], r.Name, "invalid comment %q. %v", str, err)
+				continue
+			}
+			comment.comments = append(comment.comments, strings.TrimSpace(str))
+			comment.templates = append(comment.templates, tmpl)
+		}
+		if len(r.Comments) == 0 && !r.legacy {
+			errs.add(r.line, r.Name, "at least one comment is required")
+		}
+
+		comments = append(comments, comment)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return comments, nil
+}
+
+// probability is the weight of the comment with the weighted strategy.
+func (c *comment) probability() int {
+	if c.weight == 0 {
+		return 1
+	}
+
+	return c.weight
+}
@@ -0,0 +1,257 @@
+package weplus
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// compiled is a comment with the match expression as a string.
+type compiled struct {
+	line      int
+	name      string
+	raw       string
+	weight    int
+	sentiment string
+	match     string
+	comments  []string
+}
+
+func compile(comments []*comment) []*compiled {
+	res := []*compiled{}
+	for _, c := range comments {
+		match := ""
+		if c.match != nil {
+			match = c.match.String()
+		}
+		res = append(res, &compiled{line: c.line, name: c.name, raw: c.raw, weight: c.weight, sentiment: c.sentiment, match: match, comments: c.comments})
+	}
+	return res
+}
+
+func TestLoadComments(t *testing.T) {
+	want := []*compiled{
+		{line: 3, name: "long", raw: "duration > 120", weight: 100, match: `duration > "120"`, comments: []string{"Damn... {{Duration}} minutes!", "💪💪💪"}},
+		{line: 8, name: "yoga", raw: "duration >= 90 && type == yoga", match: `(duration >= "90" && type == "yoga")`, comments: []string{"Thats a long | good Yoga pass"}},
+		{line: 13, name: "negative", raw: "sentiment == neg", sentiment: "neg", comments: []string{"Get well soon"}},
+	}
+
+	cases := []struct {
+		name string
+		raw  string
+		want []*compiled
+	}{
+		{
+			name: "yaml",
+			raw: `version: 1
+rules:
+  - name: long
+    weight: 100
+    match: duration > 120
+    comments: ["Damn... {{Duration}} minutes!", "💪💪💪"]
+  # Comments can contain a literal |.
+  - name: yoga
+    match:
+      - duration >= 90
+      - type == yoga
+    comments: Thats a long | good Yoga pass
+  - name: negative
+    match: [sentiment == neg]
+    comments:
+      - Get well soon
+`,
+			want: want,
+		},
+		{
+			name: "json",
+			raw: `{
+  "version": 1,
+  "rules": [
+    {"name": "long", "weight": 100, "match": ["duration > 120"],
+     "comments": ["Damn... {{Duration}} minutes!", "💪💪💪"]},
+    {},
+    {},
+    {},
+    {"name": "yoga", "match": ["duration >= 90", "type == yoga"],
+     "comments": ["Thats a long | good Yoga pass"]},
+    {},
+    {},
+    {},
+    {"name": "negative", "match": ["sentiment == neg"], "comments": ["Get well soon"]}
+  ]
+}`,
+		},
+		{
+			name: "legacy",
+			raw:  "|| 👍👍👍 | 🙌🙌\n\n100 | duration > 120 | Damn... {{Duration}} minutes!\n| type == post && name ~ Big Boss | 👍\n200 | name == big boss |",
+			want: []*compiled{
+				{line: 1, name: "line 1", comments: []string{"👍👍👍", "🙌🙌"}},
+				{line: 3, name: "line 3", raw: "duration > 120", weight: 100, match: `duration > "120"`, comments: []string{"Damn... {{Duration}} minutes!"}},
+				{line: 4, name: "line 4", raw: "type == post && name ~ Big Boss", match: `(type == "post" && name ~ "big boss")`, comments: []string{"👍"}},
+				// A legacy row without comments matches without commenting.
+				{line: 5, name: "line 5", raw: "name == big boss", weight: 200, match: `name == "big boss"`},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := loadComments([]byte(c.raw))
+
+			// The json case has empty rules as padding to get the same lines as the yaml case.
+			if c.want == nil {
+				if err == nil || !strings.Contains(err.Error(), `line 6: name is required`) {
+					t.Fatalf("expected padding rules to be invalid but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(compile(got), c.want) {
+				for _, comment := range compile(got) {
+					t.Logf("%+v", *comment)
+				}
+				t.Errorf("unexpected comments")
+			}
+		})
+	}
+}
+
+func TestLoadCommentsErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{
+			name: "missing version",
+			raw:  "rules:\n  - name: a\n    comments: [b]\n",
+			want: []string{"line 1: version is required"},
+		},
+		{
+			name: "unsupported version",
+			raw:  "version: 2\nrules: []\n",
+			want: []string{"line 1: unsupported version 2, expected 1"},
+		},
+		{
+			name: "unknown keys",
+			raw:  "version: 1\nrule:\n  - name: a\n",
+			want: []string{`line 2: unknown key "rule"`},
+		},
+		{
+			name: "invalid rules",
+			raw: `version: 1
+rules:
+  - name: first
+    weight: high
+    match:
+      - duration > 60
+      - durration > 60
+    comments: []
+  - name: first
+    comment: typo
+    comments: ["ok", " "]
+  - comments: [ok]
+`,
+			want: []string{
+				`line 4: rule "first": weight must be a whole number`,
+				`line 10: rule "first": unknown key "comment"`,
+				`line 7: rule "first": invalid expression "durration > 60". unknown key "durration"`,
+				`line 3: rule "first": at least one comment is required`,
+				`line 9: rule "first": name is already used by the rule on line 3`,
+				`line 11: rule "first": comments can't be empty`,
+				`line 12: name is required`,
+			},
+		},
+		{
+			name: "invalid template",
+			raw:  "version: 1\nrules:\n  - name: a\n    comments:\n      - ok {{first .Name}}\n      - \"{{.Nmae}}\"\n",
+			want: []string{`line 6: rule "a": invalid comment "{{.Nmae}}". template: comment:1:2: executing "comment" at <.Nmae>: can't evaluate field Nmae`},
+		},
+		{
+			name: "strategy",
+			raw:  "version: 1\nstrategy: random\nrules:\n  - name: a\n    comments: [b]\n",
+			want: []string{`line 2: unknown strategy "random", expected priority or weighted`},
+		},
+		{
+			name: "negative weight",
+			raw:  "version: 1\nstrategy: weighted\nrules:\n  - name: a\n    weight: -1\n    comments: [b]\n",
+			want: []string{`line 4: rule "a": weight can't be negative with the weighted strategy`},
+		},
+		{
+			name: "unknown timezone",
+			raw:  "version: 1\nrules:\n  - name: a\n    comments: [b]\ntimezone: Europe/Gothenburg\n",
+			want: []string{`line 5: unknown timezone "Europe/Gothenburg"`},
+		},
+		{
+			name: "yaml syntax",
+			raw:  "version: 1\nrules:\n  - name: a\n    comments:\n      - {{Duration}} minutes\n",
+			want: []string{"couldn't parse rule file. yaml: line 4"},
+		},
+		{
+			name: "legacy",
+			raw:  "|| ok\nten | duration > 5 | ok\n| durration > 5 | ok\n| type == post |\n",
+			want: []string{"line 2: couldn't convert weight ten to int"},
+		},
+		{
+			name: "legacy or",
+			raw:  "|| ok\n| sentiment == pos || duration > 999 | B\n",
+			want: []string{"line 2: expressions with || or | can't be used in the legacy format, use the yaml format"},
+		},
+		{
+			name: "legacy expressions",
+			raw:  "|| ok\n| durration > 5 | ok\n| type == post |\n",
+			want: []string{`line 2: rule "line 2": invalid expression "durration > 5"`},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := loadComments([]byte(c.raw))
+			if err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			for _, want := range c.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected error to contain %q but got %q", want, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestExampleComments(t *testing.T) {
+	for _, file := range []string{"setter/comments.example.yaml", "setter/comments.example.txt"} {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ValidateComments(raw); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+func TestLoadCommentsTimezone(t *testing.T) {
+	cases := map[string]string{
+		"timezone: Europe/Stockholm\nversion: 1\nrules:\n  - name: a\n    comments: [b]\n": "Europe/Stockholm",
+		"version: 1\nrules:\n  - name: a\n    comments: [b]\n":                             "UTC",
+		"|| b\n": "UTC",
+	}
+
+	for raw, want := range cases {
+		comments, err := loadComments([]byte(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := comments[0].loc.String(); got != want {
+			t.Errorf("expected timezone %s but got %s", want, got)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package weplus
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// schedule is when planned actions are sent. Actions are spread over the spread
+// window after they are planned, only sent during the active hours and not until
+// the post is minAge old.
+type schedule struct {
+	spread time.Duration
+	minAge time.Duration
+	// from and to are the active hours as durations since midnight in loc. They are
+	// both 0 if every hour is active and to is before from if they wrap midnight.
+	from time.Duration
+	to   time.Duration
+	loc  *time.Location
+}
+
+// newSchedule returns the schedule in inp, or nil if actions are sent right away.
+func newSchedule(inp *Input, loc *time.Location) (*schedule, error) {
+	if inp.SpreadMinutes == nil && inp.MinAgeMinutes == nil && inp.ActiveHours == "" {
+		return nil, nil
+	}
+
+	s := &schedule{loc: loc}
+	if s.loc == nil {
+		s.loc = time.UTC
+	}
+
+	if inp.SpreadMinutes != nil {
+		if *inp.SpreadMinutes < 0 {
+			return nil, fmt.Errorf("spreadMinutes can't be negative")
+		}
+		s.spread = time.Duration(*inp.SpreadMinutes) * time.Minute
+	}
+
+	if inp.MinAgeMinutes != nil {
+		if *inp.MinAgeMinutes < 0 {
+			return nil, fmt.Errorf("minAgeMinutes can't be negative")
+		}
+		s.minAge = time.Duration(*inp.MinAgeMinutes) * time.Minute
+	}
+
+	if inp.ActiveHours != "" {
+		from, to, err := parseActiveHours(inp.ActiveHours)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse activeHours %s. %w", inp.ActiveHours, err)
+		}
+		s.from, s.to = from, to
+	}
+
+	return s, nil
+}
+
+// parseActiveHours parses hours such as 07:00-21:00.
+func parseActiveHours(str string) (time.Duration, time.Duration, error) {
+	parts := strings.Split(str, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected hours such as 07:00-21:00")
+	}
+
+	hours := []time.Duration{}
+	for _, part := range parts {
+		t, err := time.Parse(timeFormat, strings.TrimSpace(part))
+		if err != nil {
+			return 0, 0, fmt.Errorf("expected hours such as 07:00-21:00")
+		}
+		hours = append(hours, time.Duration(t.Hour())*time.Hour+time.Duration(t.Minute())*time.Minute)
+	}
+
+	if hours[0] == hours[1] {
+		return 0, 0, fmt.Errorf("the hours can't start and end at the same time")
+	}
+
+	return hours[0], hours[1], nil
+}
+
+// active returns if t is within the active hours.
+func (s *schedule) active(t time.Time) bool {
+	if s == nil || s.from == s.to {
+		return true
+	}
+
+	t = t.In(s.loc)
+	tod := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if s.from < s.to {
+		return tod >= s.from && tod < s.to
+	}
+
+	return tod >= s.from || tod < s.to
+}
+
+// next returns t if it's within the active hours, otherwise when they next start.
+func (s *schedule) next(t time.Time) time.Time {
+	if s.active(t) {
+		return t
+	}
+
+	// The start is wall clock time, so it's the same hour on days when the clock changes.
+	l := t.In(s.loc)
+	hour, min := int(s.from/time.Hour), int(s.from%time.Hour/time.Minute)
+	start := time.Date(l.Year(), l.Month(), l.Day(), hour, min, 0, 0, s.loc)
+	if start.Before(t) {
+		start = time.Date(l.Year(), l.Month(), l.Day()+1, hour, min, 0, 0, s.loc)
+	}
+
+	return start
+}
+
+// due returns when an action on a post made at date should be sent.
+func (s *schedule) due(rng *rand.Rand, date time.Time, now time.Time) time.Time {
+	if s == nil {
+		return now
+	}
+
+	due := now
+	if aged := date.Add(s.minAge); aged.After(due) {
+		due = aged
+	}
+	if s.spread > 0 {
+		due = due.Add(time.Duration(rng.Int63n(int64(s.spread))))
+	}
+
+	return s.next(due)
+}
@@ -0,0 +1,133 @@
+package weplus
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestParseActiveHours(t *testing.T) {
+	cases := []struct {
+		str  string
+		from time.Duration
+		to   time.Duration
+		err  bool
+	}{
+		{str: "07:00-21:00", from: 7 * time.Hour, to: 21 * time.Hour},
+		{str: "22:30 - 06:00", from: 22*time.Hour + 30*time.Minute, to: 6 * time.Hour},
+		{str: "07:00", err: true},
+		{str: "7-21", err: true},
+		{str: "07:00-07:00", err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.str, func(t *testing.T) {
+			from, to, err := parseActiveHours(c.str)
+			if (err != nil) != c.err {
+				t.Fatalf("expected error %t but got %v", c.err, err)
+			}
+			if from != c.from || to != c.to {
+				t.Errorf("expected %s-%s but got %s-%s", c.from, c.to, from, to)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	stockholm, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := &schedule{from: 7 * time.Hour, to: 21 * time.Hour, loc: stockholm}
+	night := &schedule{from: 22 * time.Hour, to: 6 * time.Hour, loc: time.UTC}
+
+	cases := []struct {
+		name     string
+		schedule *schedule
+		t        time.Time
+		want     time.Time
+	}{
+		{name: "no schedule", t: time.Date(2021, 3, 20, 3, 0, 0, 0, time.UTC), want: time.Date(2021, 3, 20, 3, 0, 0, 0, time.UTC)},
+		{name: "active", schedule: day, t: time.Date(2021, 3, 20, 12, 0, 0, 0, stockholm), want: time.Date(2021, 3, 20, 12, 0, 0, 0, stockholm)},
+		{name: "before", schedule: day, t: time.Date(2021, 3, 20, 5, 0, 0, 0, stockholm), want: time.Date(2021, 3, 20, 7, 0, 0, 0, stockholm)},
+		{name: "after", schedule: day, t: time.Date(2021, 3, 20, 22, 0, 0, 0, stockholm), want: time.Date(2021, 3, 21, 7, 0, 0, 0, stockholm)},
+		{name: "summer time starts", schedule: day, t: time.Date(2021, 3, 28, 5, 0, 0, 0, stockholm), want: time.Date(2021, 3, 28, 7, 0, 0, 0, stockholm)},
+		{name: "summer time ends", schedule: day, t: time.Date(2021, 10, 30, 22, 0, 0, 0, stockholm), want: time.Date(2021, 10, 31, 7, 0, 0, 0, stockholm)},
+		{name: "in another timezone", schedule: day, t: time.Date(2021, 3, 20, 5, 0, 0, 0, time.UTC), want: time.Date(2021, 3, 20, 7, 0, 0, 0, stockholm)},
+		{name: "wraps midnight", schedule: night, t: time.Date(2021, 3, 20, 3, 0, 0, 0, time.UTC), want: time.Date(2021, 3, 20, 3, 0, 0, 0, time.UTC)},
+		{name: "before wrapping", schedule: night, t: time.Date(2021, 3, 20, 12, 0, 0, 0, time.UTC), want: time.Date(2021, 3, 20, 22, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.schedule.next(c.t); !got.Equal(c.want) {
+				t.Errorf("expected %s but got %s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestScheduleDue(t *testing.T) {
+	now := time.Date(2021, 3, 20, 12, 0, 0, 0, time.UTC)
+	s := &schedule{spread: 3 * time.Hour, minAge: time.Hour, loc: time.UTC}
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 100; i++ {
+		// Old posts are spread over the window and new ones from when they are old enough.
+		if due := s.due(rng, now.Add(-2*time.Hour), now); due.Before(now) || !due.Before(now.Add(3*time.Hour)) {
+			t.Fatalf("expected %s to be within the spread window", due)
+		}
+		if due := s.due(rng, now.Add(-10*time.Minute), now); due.Before(now.Add(50 * time.Minute)) {
+			t.Fatalf("expected %s to be after the post is an hour old", due)
+		}
+	}
+}
+
+func TestRunSchedule(t *testing.T) {
+	fw := newFakeWeplus(t)
+	fw.add("group",
+		&fakePost{ID: "2000001", UserID: "10002", Name: "Cecilia Carlsson", Group: "@Hawks", Duration: 30, Kind: "Yoga", Date: time.Now().Add(-2 * time.Hour)},
+		&fakePost{ID: "2000002", UserID: "10003", Name: "David Dahl", Group: "@Hawks", Duration: 45, Kind: "Löpning", Date: time.Now().Add(-time.Minute)},
+	)
+
+	store := fw.newStore("| type == group | Nice")
+
+	hour := 60
+	run := func(t *testing.T) *Report {
+		return fw.run(t, store, &Input{Email: fw.email, MinAgeMinutes: &hour})
+	}
+
+	// The old post is sent right away and the new one when it's an hour old.
+	report := run(t)
+	if report.Counts.Liked != 1 || report.Counts.Scheduled != 1 {
+		t.Fatalf("expected 1 liked and 1 scheduled but got %+v", report.Counts)
+	}
+
+	raw, _ := store.download("erik@example.com.json")
+	d := &data{}
+	if err := json.Unmarshal(raw, d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Pending) != 1 || d.Pending[0].PostID != "2000002" || d.Pending[0].Due == nil || d.Pending[0].Due.Before(time.Now().Add(50*time.Minute)) {
+		t.Fatalf("expected post 2000002 to be due in an hour but got %s", raw)
+	}
+
+	// Nothing is sent until it's due.
+	if report := run(t); report.Counts.Liked != 0 || report.Counts.Commented != 0 {
+		t.Fatalf("expected nothing to be sent but got %+v", report.Counts)
+	}
+
+	due := time.Now().Add(-time.Minute)
+	d.Pending[0].Due = &due
+	raw, _ = json.Marshal(d)
+	store.save("erik@example.com.json", raw)
+
+	report = run(t)
+	if report.Counts.Liked != 1 || report.Counts.Commented != 1 || !report.Actions[0].Resumed {
+		t.Fatalf("expected the due post to be sent but got %+v", report.Counts)
+	}
+	if got := fw.liked(); len(got) != 2 || got[1] != "2000002" {
+		t.Errorf("expected both posts to be liked but got %q", got)
+	}
+}
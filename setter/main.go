@@ -10,6 +10,7 @@ import (
 	"log"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -20,6 +21,13 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/nuttmeister/weplus"
+)
+
+// The comments and people file extensions read by the function, txt is the legacy format.
+var (
+	commentsExts = []string{"yaml", "yml", "json", "txt"}
+	peopleExts   = []string{"yaml", "yml", "json"}
 )
 
 type cfg struct {
@@ -40,7 +48,7 @@ func main() {
 		log.Fatal(err)
 	}
 
-	email, password, commentsFile, createEvent, enableEvent, disableEvent, err := input()
+	email, password, commentsFile, peopleFile, createEvent, enableEvent, disableEvent, err := input()
 	if err != nil {
 		flag.Usage()
 		os.Exit(1)
@@ -53,13 +61,27 @@ func main() {
 			log.Fatal(err)
 		}
 
-		if err := cfg.upload(email, comments); err != nil {
+		if err := cfg.upload(email, "comments", commentsExt(commentsFile), commentsExts, comments); err != nil {
 			log.Fatal(err)
 		}
 
 		fmt.Printf("finished uploading comments for %s\n", email)
 	}
 
+	// Read and upload people if people file as supplied.
+	if peopleFile != "" {
+		people, ext, err := readPeople(peopleFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := cfg.upload(email, "people", ext, peopleExts, people); err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("finished uploading people for %s\n", email)
+	}
+
 	// Set password if it was supplied.
 	if password != "" {
 		encryptedPassword, err := cfg.encrypt(password)
@@ -124,9 +146,10 @@ func configure() (*cfg, error) {
 	return cfg, nil
 }
 
-func input() (string, string, string, bool, bool, bool, error) {
+func input() (string, string, string, string, bool, bool, bool, error) {
 	email := flag.String("email", "", "the email to upload data for [*required]")
 	commentsFile := flag.String("comments", "", "the comments file to use. leave empty to not upload comments")
+	peopleFile := flag.String("people", "", "the yaml or json people file to use. leave empty to not upload people")
 	password := flag.String("password", "", "the password to set. leave empty to not update password")
 	createEvent := flag.Bool("create-event", false, "use this flag to create or update the event")
 	enableEvent := flag.Bool("enable-event", false, "use this flag to set the event as enabled")
@@ -135,10 +158,10 @@ func input() (string, string, string, bool, bool, bool, error) {
 
 	switch {
 	case *email == "":
-		return "", "", "", false, false, false, fmt.Errorf("input email is required")
+		return "", "", "", "", false, false, false, fmt.Errorf("input email is required")
 	}
 
-	return *email, *password, *commentsFile, *createEvent, *enableEvent, *disableEvent, nil
+	return *email, *password, *commentsFile, *peopleFile, *createEvent, *enableEvent, *disableEvent, nil
 }
 
 func readComments(commentsFile string) ([]byte, error) {
@@ -153,19 +176,70 @@ func readComments(commentsFile string) ([]byte, error) {
 		}
 	}
 
+	if err := weplus.ValidateComments(comments); err != nil {
+		return nil, fmt.Errorf("%s is not valid. %w", commentsFile, err)
+	}
+
 	return comments, nil
 }
 
-func (cfg *cfg) upload(email string, comments []byte) error {
-	commentsFile := fmt.Sprintf("%s.comments.txt", strings.ToLower(email))
+// commentsExt returns the extension to upload the comments file with. Anything that
+// isn't yaml or json is uploaded as the legacy txt format.
+func commentsExt(commentsFile string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(commentsFile), "."))
+	for _, e := range commentsExts {
+		if ext == e {
+			return ext
+		}
+	}
+
+	return "txt"
+}
+
+func readPeople(peopleFile string) ([]byte, string, error) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(peopleFile), "."))
+	if ext != "yaml" && ext != "yml" && ext != "json" {
+		return nil, "", fmt.Errorf("%s must be a yaml or json file", peopleFile)
+	}
+
+	people, err := os.ReadFile(peopleFile)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := weplus.ValidatePeople(people); err != nil {
+		return nil, "", fmt.Errorf("%s is not valid. %w", peopleFile, err)
+	}
+
+	return people, ext, nil
+}
+
+// upload uploads the comments or people file of the user as <email>.<name>.<ext>.
+func (cfg *cfg) upload(email string, name string, ext string, exts []string, raw []byte) error {
+	file := fmt.Sprintf("%s.%s.%s", strings.ToLower(email), name, ext)
 
 	_, err := cfg.s3.PutObject(cfg.ctx, &s3.PutObjectInput{
 		Bucket: &cfg.Bucket,
-		Key:    &commentsFile,
-		Body:   bytes.NewReader(comments),
+		Key:    &file,
+		Body:   bytes.NewReader(raw),
 	})
+	if err != nil {
+		return err
+	}
 
-	return err
+	// Remove files with other extensions, otherwise they might be read instead.
+	for _, e := range exts {
+		if e == ext {
+			continue
+		}
+
+		oldFile := fmt.Sprintf("%s.%s.%s", strings.ToLower(email), name, e)
+		if _, err := cfg.s3.DeleteObject(cfg.ctx, &s3.DeleteObjectInput{Bucket: &cfg.Bucket, Key: &oldFile}); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (cfg *cfg) encrypt(password string) (string, error) {
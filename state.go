@@ -0,0 +1,69 @@
+package weplus
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// The state files without a version are version 1, where the seen posts are lists of ids.
+const stateVersion = 2
+
+// The newest keepSeen posts of a feed are never pruned, so a feed without new posts
+// for a long time still has seen posts to stop fetching at.
+const keepSeen = 24
+
+// seenPosts are the ids of the seen posts of a feed and when they were first seen.
+type seenPosts map[string]time.Time
+
+// UnmarshalJSON reads the seen posts, or the list of ids of state version 1. The ids
+// in a list are first seen now.
+func (s *seenPosts) UnmarshalJSON(raw []byte) error {
+	if string(raw) == "null" {
+		return nil
+	}
+
+	ids := []string{}
+	if err := json.Unmarshal(raw, &ids); err == nil {
+		now := time.Now()
+		*s = seenPosts{}
+		for _, id := range ids {
+			(*s)[id] = now
+		}
+		return nil
+	}
+
+	posts := map[string]time.Time{}
+	if err := json.Unmarshal(raw, &posts); err != nil {
+		return err
+	}
+	*s = posts
+
+	return nil
+}
+
+func (s seenPosts) has(id string) bool {
+	_, ok := s[id]
+	return ok
+}
+
+// prune removes the posts first seen before before, except the newest keepSeen.
+func (s seenPosts) prune(before time.Time) {
+	ids := make([]string, 0, len(s))
+	for id := range s {
+		ids = append(ids, id)
+	}
+	// Post ids are numbers that grow, so the longest and then largest ids are the newest.
+	sort.Slice(ids, func(i, j int) bool {
+		if len(ids[i]) != len(ids[j]) {
+			return len(ids[i]) > len(ids[j])
+		}
+		return ids[i] > ids[j]
+	})
+
+	for i, id := range ids {
+		if i >= keepSeen && s[id].Before(before) {
+			delete(s, id)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package weplus
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// seenIDs returns the sorted ids of the seen posts.
+func seenIDs(s seenPosts) []string {
+	ids := []string{}
+	for id := range s {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func TestLoadState(t *testing.T) {
+	first := time.Date(2021, 3, 20, 7, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name    string
+		raw     string
+		group   []string
+		company []string
+		date    *time.Time
+	}{
+		{name: "version 1", raw: `{"group":["2000001","2000002"],"company":["3000001"]}`, group: []string{"2000001", "2000002"}, company: []string{"3000001"}},
+		{name: "version 1 without posts", raw: `{"group":[],"company":null}`, group: []string{}, company: []string{}},
+		{name: "version 2 without posts", raw: `{"version":2,"group":null}`, group: []string{}, company: []string{}},
+		{name: "version 2", raw: `{"version":2,"group":{"2000001":"2021-03-20T07:00:00Z"},"company":{}}`, group: []string{"2000001"}, company: []string{}, date: &first},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := newMemStore()
+			store.save("erik@example.com.comments.txt", []byte("|| Nice"))
+			store.save("erik@example.com.json", []byte(c.raw))
+
+			before := time.Now()
+			d, _, err := (&cfg{store: store}).load(&Input{Email: "erik@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := seenIDs(d.Group); !reflect.DeepEqual(got, c.group) {
+				t.Errorf("expected group %q but got %q", c.group, got)
+			}
+			if got := seenIDs(d.Company); !reflect.DeepEqual(got, c.company) {
+				t.Errorf("expected company %q but got %q", c.company, got)
+			}
+
+			// Migrated posts are first seen when they are migrated.
+			for id, date := range d.Group {
+				if (c.date != nil && !date.Equal(*c.date)) || (c.date == nil && date.Before(before)) {
+					t.Errorf("unexpected first seen %s for %s", date, id)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadStateErrors(t *testing.T) {
+	store := newMemStore()
+	store.save("erik@example.com.comments.txt", []byte("|| Nice"))
+	store.save("erik@example.com.json", []byte(`{"version":3,"group":{},"company":{}}`))
+
+	if _, _, err := (&cfg{store: store}).load(&Input{Email: "erik@example.com"}); err == nil {
+		t.Error("expected an error for a newer state version")
+	}
+}
+
+func TestSaveState(t *testing.T) {
+	now := time.Now()
+	store := newMemStore()
+	cfg := &cfg{store: store, seenHorizon: 30 * 24 * time.Hour}
+	d := &data{
+		// The group feed hasn't had new posts for a long time, but its newest posts are kept.
+		Group:   seenPosts{"2000001": now.Add(-31 * 24 * time.Hour), "2000002": now.Add(-29 * 24 * time.Hour)},
+		Company: seenPosts{"999999": now.Add(-40 * 24 * time.Hour), "500000": now.Add(-24 * time.Hour)},
+	}
+	want := []string{"500000"}
+	for i := 0; i <= keepSeen; i++ {
+		id := strconv.Itoa(1000000 + i)
+		d.Company[id] = now.Add(-40 * 24 * time.Hour)
+		if i > 0 {
+			want = append(want, id)
+		}
+	}
+	sort.Strings(want)
+
+	if err := cfg.save(&Input{Email: "erik@example.com"}, d); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := store.download("erik@example.com.json")
+	saved := &data{}
+	if err := json.Unmarshal(raw, saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Version != stateVersion || !reflect.DeepEqual(seenIDs(saved.Group), []string{"2000001", "2000002"}) || !reflect.DeepEqual(seenIDs(saved.Company), want) {
+		t.Errorf("expected the newest posts and the posts seen within the horizon to be saved but got %s", raw)
+	}
+}
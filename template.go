@@ -0,0 +1,178 @@
+package weplus
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+	// The legacy variables such as {{Name}} or {{name}} are converted to {{.Name}}.
+	legacyVarRegexp = regexp.MustCompile(`\{\{[ ]*(?i)(name|group|duration|type|time)[ ]*\}\}`)
+
+	templateFuncs = template.FuncMap{
+		"first":  firstName,
+		"hours":  hours,
+		"plural": plural,
+		// pick is replaced with one using the rng of the run when rendered.
+		"pick":  picker(nil),
+		"emoji": emoji,
+	}
+
+	// The emojis for workout types, the first one where the type contains any of the names is used.
+	typeEmojis = []struct {
+		names []string
+		emoji string
+	}{
+		{names: []string{"löp", "run", "jogg"}, emoji: "🏃"},
+		{names: []string{"cykl", "cycl", "spinning", "bike"}, emoji: "🚴"},
+		{names: []string{"sim", "swim"}, emoji: "🏊"},
+		{names: []string{"promenad", "walk", "gång", "vandring", "hike"}, emoji: "🚶"},
+		{names: []string{"yoga"}, emoji: "🧘"},
+		{names: []string{"styrka", "gym", "strength", "weight"}, emoji: "🏋️"},
+		{names: []string{"skid", "ski"}, emoji: "⛷️"},
+	}
+
+	// samplePost is used to validate templates when they are loaded.
+	samplePost = &post{
+		name:             "Anna Andersson",
+		groupName:        "@Sample",
+		trainingType:     "Löpning",
+		trainingDuration: "45",
+		exercise:         true,
+		date:             time.Date(2021, 3, 20, 7, 0, 0, 0, time.UTC),
+	}
+)
+
+const defEmoji = "💪"
+
+// templateData is what comments are rendered with, such as {{.Name}} or {{first .Name}}.
+type templateData struct {
+	Name      string
+	Group     string
+	Type      string
+	Duration  int
+	Time      string
+	Date      time.Time
+	Text      string
+	Sentiment string
+	Exercise  bool
+}
+
+func newTemplateData(post *post, loc *time.Location) *templateData {
+	date := post.date
+	if loc != nil {
+		date = date.In(loc)
+	}
+
+	// Posts without exercises don't have a duration.
+	duration, _ := strconv.Atoi(post.trainingDuration)
+
+	return &templateData{
+		Name:      post.name,
+		Group:     post.groupName,
+		Type:      post.trainingType,
+		Duration:  duration,
+		Time:      date.Format(timeFormat),
+		Date:      date,
+		Text:      post.text,
+		Sentiment: string(post.sentiment),
+		Exercise:  post.exercise,
+	}
+}
+
+// parseTemplate parses a comment as a template and checks that it can be rendered.
+func parseTemplate(str string) (*template.Template, error) {
+	str = legacyVarRegexp.ReplaceAllStringFunc(str, func(match string) string {
+		name := strings.ToLower(legacyVarRegexp.FindStringSubmatch(match)[1])
+		return fmt.Sprintf("{{.%s}}", strings.ToUpper(name[:1])+name[1:])
+	})
+
+	tmpl, err := template.New("comment").Funcs(templateFuncs).Parse(str)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := render(tmpl, samplePost, nil, rand.New(rand.NewSource(1))); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// render renders the comment template for the post. Time is in loc, or the timezone
+// of the post if loc is nil, and rng is used for the random choices.
+func render(tmpl *template.Template, post *post, loc *time.Location, rng *rand.Rand) (string, error) {
+	tmpl, err := tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	tmpl.Funcs(template.FuncMap{"pick": picker(rng)})
+
+	b := &strings.Builder{}
+	if err := tmpl.Execute(b, newTemplateData(post, loc)); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
+
+// firstName returns the first name of name, such as Anna for Anna Andersson.
+func firstName(name string) string {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return name
+	}
+
+	return fields[0]
+}
+
+// hours formats minutes as hours and minutes, such as 1h 30m.
+func hours(minutes int) string {
+	switch {
+	case minutes < 60:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("%dh", minutes/60)
+	}
+
+	return fmt.Sprintf("%dh %dm", minutes/60, minutes%60)
+}
+
+// plural returns n with the singular or plural word, such as 1 minute or 2 minutes.
+func plural(n int, singular string, plural string) string {
+	if n == 1 || n == -1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// picker returns a func that returns one of the alternatives at random.
+func picker(rng *rand.Rand) func(alternatives ...string) string {
+	return func(alternatives ...string) string {
+		if len(alternatives) == 0 {
+			return ""
+		}
+
+		return alternatives[rng.Intn(len(alternatives))]
+	}
+}
+
+// emoji returns an emoji for the workout type.
+func emoji(kind string) string {
+	kind = strings.ToLower(kind)
+	for _, e := range typeEmojis {
+		for _, name := range e.names {
+			if strings.Contains(kind, name) {
+				return e.emoji
+			}
+		}
+	}
+
+	return defEmoji
+}
@@ -0,0 +1,69 @@
+package weplus
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/comprehend/types"
+)
+
+func TestRender(t *testing.T) {
+	run := &post{name: "Big Boss", groupName: "@Hawks", trainingType: "Löpning", trainingDuration: "90", exercise: true, date: time.Date(2021, 3, 20, 6, 30, 0, 0, time.UTC), sentiment: types.SentimentTypePositive}
+	status := &post{name: "Cecilia", text: "Hello!", date: run.date}
+
+	cases := []struct {
+		tmpl string
+		post *post
+		want string
+	}{
+		{tmpl: "Go {{Name}} and {{name}}, {{ Duration }} minutes of {{type}} at {{Time}} for {{Group}}", post: run, want: "Go Big Boss and Big Boss, 90 minutes of Löpning at 06:30 for @Hawks"},
+		{tmpl: "Go {{first .Name}}! {{hours .Duration}} of {{.Type}} {{emoji .Type}}", post: run, want: "Go Big! 1h 30m of Löpning 🏃"},
+		{tmpl: "{{plural .Duration \"minute\" \"minutes\"}} {{plural 1 \"minute\" \"minutes\"}}", post: run, want: "90 minutes 1 minute"},
+		{tmpl: "{{pick \"Nice\"}} {{.Date.Format \"Monday\"}}", post: run, want: "Nice Saturday"},
+		{tmpl: "{{if .Exercise}}{{.Duration}}{{else}}{{first .Name}} said {{.Text}}{{end}}", post: status, want: "Cecilia said Hello!"},
+		{tmpl: "{{if eq .Sentiment \"POSITIVE\"}}😀{{end}} {{emoji \"Spinning\"}} {{emoji \"Curling\"}}", post: run, want: "😀 🚴 💪"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.tmpl, func(t *testing.T) {
+			tmpl, err := parseTemplate(c.tmpl)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := render(tmpl, c.post, nil, rand.New(rand.NewSource(1)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("expected %q but got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTemplateErrors(t *testing.T) {
+	cases := map[string]string{
+		"{{.Nmae}}":                  "can't evaluate field Nmae",
+		"{{Nmae}}":                   `function "Nmae" not defined`,
+		"{{if .Exercise}}":           "unexpected EOF",
+		"{{hours .Name}}":            "wrong type for value",
+		"{{plural .Duration \"a\"}}": "wrong number of args for plural",
+	}
+
+	for tmpl, want := range cases {
+		if _, err := parseTemplate(tmpl); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %s to fail with %q but got %v", tmpl, want, err)
+		}
+	}
+}
+
+func TestHours(t *testing.T) {
+	for minutes, want := range map[int]string{0: "0m", 45: "45m", 60: "1h", 61: "1h 1m", 150: "2h 30m"} {
+		if got := hours(minutes); got != want {
+			t.Errorf("expected %d minutes to be %s but got %s", minutes, want, got)
+		}
+	}
+}
@@ -0,0 +1,1205 @@
+package weplus
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	// Embed the timezone database, it isn't available in the lambda runtime.
+	_ "time/tzdata"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/comprehend"
+	"github.com/aws/aws-sdk-go-v2/service/comprehend/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const (
+	dateFormat = "Mon, 02 Jan 2006 15:04:05 -0700"
+	timeFormat = "15:04"
+)
+
+var (
+	textRegexp   = regexp.MustCompile(`(?s)<div class="post-body">[ \n]*<p>(.*)</p></div>`)
+	userIDRegexp = regexp.MustCompile(`<a href="/users/([0-9]{5})">[ \n]*<i class="fas fa-chart-bar"></i>[ \n]*My Statistics[ \n]*</a>`)
+	tokenRegexp  = regexp.MustCompile(`<meta name="csrf-token" content="([A-Za-z0-9+/=]*)" />`)
+
+	defLikeRatio    = 1.0
+	defCommentRatio = 0.8
+
+	defGroupLikeRatio    = 1.0
+	defGroupCommentRatio = 1.0
+
+	defCommentWindowDays = 7
+	defSeenDays          = 90
+
+	defBaseURL = "https://www.weplusapp.com"
+	defAccept  = "text/javascript, application/javascript, application/ecmascript, application/x-ecmascript, */*; q=0.01"
+)
+
+// Handler runs the bot for the user in inp using S3, KMS and Comprehend.
+func Handler(ctx context.Context, inp *Input) (*Report, error) {
+	// Create new config.
+	cfg, err := new(ctx, 15000)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.run(inp)
+}
+
+func (cfg *cfg) run(inp *Input) (*Report, error) {
+	// Get and decrypt password.
+	if err := cfg.parse(inp); err != nil {
+		return nil, err
+	}
+	cfg.report = newReport(inp)
+	cfg.report.Seed = cfg.seed
+
+	// Only one run at a time changes the state of a user. Dry runs don't change it.
+	if !inp.DryRun {
+		if err := cfg.lock(inp.Email); err != nil {
+			if errors.Is(err, errLocked) {
+				cfg.report.Locked = true
+				return cfg.report.finish(), nil
+			}
+			return nil, err
+		}
+		defer cfg.unlock(inp.Email)
+	}
+
+	// Load previous states data and comments.
+	data, comments, err := cfg.load(inp)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get auth token and do auth.
+	if err := cfg.login(inp); err != nil {
+		return nil, err
+	}
+
+	// Get group ids.
+	groupIds, err := cfg.getFeed(data.Group, "group", "created-at", "all", "", "0")
+	if err != nil {
+		return nil, err
+	}
+
+	// Get company ids.
+	companyIds, err := cfg.getFeed(data.Company, "company", "created-at", "image-or-video", "", "0")
+	if err != nil {
+		return nil, err
+	}
+
+	// Save what was done even if the run fails halfway, or it's done again next run.
+	if err := cfg.process(data, groupIds, companyIds, comments, inp); err != nil {
+		if !inp.DryRun {
+			if serr := cfg.save(inp, data); serr != nil {
+				cfg.warn("couldn't save state after error. %s", serr.Error())
+			}
+		}
+		return nil, err
+	}
+
+	// Nothing was done on a dry run so leave the state as it is.
+	if !inp.DryRun {
+		if err := cfg.save(inp, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg.report.finish(), nil
+}
+
+func (cfg *cfg) process(data *data, groupIds []*post, companyIds []*post, comments []*comment, inp *Input) error {
+	// An action that was being sent when an earlier run failed might have been sent.
+	// It's retried first, what was already done isn't done again.
+	if p := data.InFlight; p != nil {
+		cfg.warn("retrying %s post %s, an earlier run failed while liking or commenting it", p.Feed, p.PostID)
+		// The like might have been sent, so it's no longer known if the post is liked.
+		p.Due = nil
+		p.Unliked = false
+		data.Pending = append([]*pending{p}, data.Pending...)
+		data.InFlight = nil
+	}
+
+	// Do what was deferred in earlier runs first, it's older than the new posts.
+	cfg.budget = newBudget(inp, data, time.Now())
+	if err := cfg.processPending(data, inp); err != nil {
+		return err
+	}
+
+	// Posts that weren't processed before the deadline of an earlier run are processed first.
+	groupIds = data.resume("group", groupIds, data.Group)
+	companyIds = data.resume("company", companyIds, data.Company)
+
+	// Process group.
+	if err := cfg.processGroupFeeds(groupIds, data, comments, inp); err != nil {
+		return err
+	}
+
+	// Process company.
+	return cfg.processCompanyFeeds(companyIds, data, comments, inp)
+}
+
+type cfg struct {
+	ctx      context.Context
+	store    store
+	secrets  secrets
+	analyzer analyzer
+	client   *http.Client
+
+	userID   string
+	token    string
+	password string
+	baseURL  string
+	loc      *time.Location
+	people   *people
+	report   *Report
+	budget   *budget
+	schedule *schedule
+
+	// version is the version of the state file that was loaded or last saved, and
+	// owner the id of the lock held by the run.
+	version string
+	owner   string
+
+	commentWindow time.Duration
+	seenHorizon   time.Duration
+	seed          int64
+	rand          *rand.Rand
+}
+
+// store holds the comments, state and lock files.
+type store interface {
+	download(file string) ([]byte, error)
+	downloadVersion(file string) ([]byte, string, error)
+	// saveIf saves the file if it's still at version, or doesn't exist if version is
+	// empty, and returns the new version. Otherwise it returns errConflict.
+	saveIf(file string, raw []byte, version string) (string, error)
+	remove(file string) error
+}
+
+// secrets returns the weplusapp.com password of a user.
+type secrets interface {
+	getPassword(email string) (string, error)
+}
+
+// analyzer returns the sentiment of a post text.
+type analyzer interface {
+	sentiment(text string) (types.SentimentType, error)
+}
+
+var errNotFound = errors.New("file doesn't exist")
+
+func newSeed() (int64, error) {
+	b := make([]byte, 8)
+	if _, err := crand.Read(b); err != nil {
+		return 0, fmt.Errorf("couldn't read random seed. %w", err)
+	}
+
+	return int64(binary.LittleEndian.Uint64(b)), nil
+}
+
+func (cfg *cfg) setSeed(seed int64) {
+	cfg.seed = seed
+	cfg.rand = rand.New(rand.NewSource(seed))
+}
+
+func new(ctx context.Context, timeout int) (*cfg, error) {
+	awsCfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load aws default config. %w", err)
+	}
+
+	return newWith(ctx, timeout,
+		&s3Store{ctx: ctx, client: s3.NewFromConfig(awsCfg), bucket: os.Getenv("BUCKET")},
+		&kmsSecrets{ctx: ctx, client: kms.NewFromConfig(awsCfg)},
+		&comprehendAnalyzer{ctx: ctx, client: comprehend.NewFromConfig(awsCfg)},
+	)
+}
+
+func newWith(ctx context.Context, timeout int, store store, secrets secrets, analyzer analyzer) (*cfg, error) {
+	cfg := &cfg{ctx: ctx, store: store, secrets: secrets, analyzer: analyzer, baseURL: os.Getenv("BASE_URL")}
+	if cfg.baseURL == "" {
+		cfg.baseURL = defBaseURL
+	}
+
+	// Seed from crypto/rand so runs started at the same time don't make the same choices.
+	seed, err := newSeed()
+	if err != nil {
+		return nil, err
+	}
+	cfg.setSeed(seed)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create a new cookie jar. %w", err)
+	}
+
+	cfg.client = &http.Client{
+		Timeout: time.Duration(timeout) * time.Millisecond,
+		Jar:     jar,
+	}
+
+	return cfg, nil
+}
+
+// Input is the payload of a run.
+type Input struct {
+	Email             string   `json:"email"`
+	LikeRatio         *float64 `json:"likeRatio,omitempty"`
+	CommentRatio      *float64 `json:"commentRatio,omitempty"`
+	GroupLikeRatio    *float64 `json:"groupLikeRatio,omitempty"`
+	GroupCommentRatio *float64 `json:"groupCommentRatio,omitempty"`
+	// CommentWindowDays is how many days a comment isn't repeated to the same user.
+	CommentWindowDays *int   `json:"commentWindowDays,omitempty"`
+	MarkAsSeen        bool   `json:"markAsSeen"`
+	DryRun            bool   `json:"dryRun"`
+	Timezone          string `json:"timezone,omitempty"`
+	// MaxLikes and MaxComments are how many posts are liked and commented at most per
+	// run, and MaxDailyLikes and MaxDailyComments in the last 24 hours. Posts over
+	// budget are deferred to the next run.
+	MaxLikes         *int `json:"maxLikes,omitempty"`
+	MaxComments      *int `json:"maxComments,omitempty"`
+	MaxDailyLikes    *int `json:"maxDailyLikes,omitempty"`
+	MaxDailyComments *int `json:"maxDailyComments,omitempty"`
+	// SpreadMinutes spreads the actions of a run over that many minutes, ActiveHours
+	// such as 07:00-21:00 is when they are sent and MinAgeMinutes is how old a post
+	// is before it's liked or commented. Actions not due yet are sent by a later run.
+	SpreadMinutes *int   `json:"spreadMinutes,omitempty"`
+	ActiveHours   string `json:"activeHours,omitempty"`
+	MinAgeMinutes *int   `json:"minAgeMinutes,omitempty"`
+	// SeenDays is how many days a seen post is remembered. It should be a lot longer than
+	// the time between runs.
+	SeenDays *int `json:"seenDays,omitempty"`
+	// Seed replays the random choices of an earlier run, it's in the report of every run.
+	Seed *int64 `json:"seed,omitempty"`
+}
+
+func (cfg *cfg) parse(inp *Input) error {
+	if inp.Email == "" {
+		return fmt.Errorf("email not set in input")
+	}
+
+	if inp.LikeRatio == nil {
+		inp.LikeRatio = &defLikeRatio
+	}
+
+	if inp.CommentRatio == nil {
+		inp.CommentRatio = &defCommentRatio
+	}
+
+	if inp.GroupLikeRatio == nil {
+		inp.GroupLikeRatio = &defGroupLikeRatio
+	}
+
+	if inp.GroupCommentRatio == nil {
+		inp.GroupCommentRatio = &defGroupCommentRatio
+	}
+
+	if inp.Seed != nil {
+		cfg.setSeed(*inp.Seed)
+	}
+
+	if inp.CommentWindowDays == nil {
+		inp.CommentWindowDays = &defCommentWindowDays
+	}
+	if *inp.CommentWindowDays < 0 {
+		return fmt.Errorf("commentWindowDays can't be negative")
+	}
+	cfg.commentWindow = time.Duration(*inp.CommentWindowDays) * 24 * time.Hour
+
+	if inp.SeenDays == nil {
+		inp.SeenDays = &defSeenDays
+	}
+	if *inp.SeenDays < 1 {
+		return fmt.Errorf("seenDays must be at least 1")
+	}
+	cfg.seenHorizon = time.Duration(*inp.SeenDays) * 24 * time.Hour
+
+	for name, max := range map[string]*int{"maxLikes": inp.MaxLikes, "maxComments": inp.MaxComments, "maxDailyLikes": inp.MaxDailyLikes, "maxDailyComments": inp.MaxDailyComments} {
+		if max != nil && *max < 0 {
+			return fmt.Errorf("%s can't be negative", name)
+		}
+	}
+
+	if inp.Timezone != "" {
+		loc, err := time.LoadLocation(inp.Timezone)
+		if err != nil {
+			return fmt.Errorf("couldn't load timezone %s. %w", inp.Timezone, err)
+		}
+		cfg.loc = loc
+	}
+
+	schedule, err := newSchedule(inp, cfg.loc)
+	if err != nil {
+		return err
+	}
+	cfg.schedule = schedule
+
+	pass, err := cfg.secrets.getPassword(inp.Email)
+	if err != nil {
+		return err
+	}
+
+	cfg.password = pass
+	return nil
+}
+
+// feed is how the posts of a feed are liked and commented.
+type feed struct {
+	name         string
+	likeRatio    float64
+	commentRatio float64
+	// The names of the ratios in the input, for the report.
+	likeRatioName    string
+	commentRatioName string
+	// sentiment is if the sentiment of the post text is checked before commenting.
+	sentiment bool
+	seen      seenPosts
+}
+
+func (cfg *cfg) processGroupFeeds(groupPosts []*post, data *data, comments []*comment, inp *Input) error {
+	return cfg.processFeed(&feed{
+		name:             "group",
+		likeRatio:        *inp.GroupLikeRatio,
+		commentRatio:     *inp.GroupCommentRatio,
+		likeRatioName:    "groupLikeRatio",
+		commentRatioName: "groupCommentRatio",
+		seen:             data.Group,
+	}, groupPosts, data, comments, inp)
+}
+
+func (cfg *cfg) processCompanyFeeds(companyPosts []*post, data *data, comments []*comment, inp *Input) error {
+	return cfg.processFeed(&feed{
+		name:             "company",
+		likeRatio:        *inp.LikeRatio,
+		commentRatio:     *inp.CommentRatio,
+		likeRatioName:    "likeRatio",
+		commentRatioName: "commentRatio",
+		sentiment:        true,
+		seen:             data.Company,
+	}, companyPosts, data, comments, inp)
+}
+
+// processFeed decides what to do with the posts of the feed and does it. The posts
+// that weren't seen before are added to the seen posts in data.
+func (cfg *cfg) processFeed(f *feed, posts []*post, data *data, comments []*comment, inp *Input) error {
+	// The posts that aren't seen are unprocessed until they are, so every checkpoint
+	// saves them and a run that is stopped doesn't lose the older ones.
+	data.unprocessed(f, posts)
+
+	for _, post := range posts {
+		if cfg.deadline() {
+			cfg.abort()
+			return nil
+		}
+
+		act := newAction(post, f.name)
+		act.Resumed = post.resumed
+		act.likeState = post.likeState
+		act.status = post.status
+		pf := cfg.people.apply(post.userID, f)
+		doLike, doComment, doSeen := doAction(cfg.rand, post.postID, f.seen, pf.likeRatio, pf.commentRatio)
+		switch reason := cfg.people.skip(post.userID); {
+		case doSeen:
+			act.skip("already seen")
+		case inp.MarkAsSeen:
+			act.skip("marking as seen")
+		case reason != "":
+			act.skip(reason)
+		default:
+			if doComment {
+				if f.sentiment {
+					if err := cfg.sentiment(post); err != nil {
+						cfg.warn("couldn't get sentiment for text %s. %s", post.text, err.Error())
+					}
+					act.Sentiment = string(post.sentiment)
+				}
+
+				doLike = true
+				cfg.choose(act, comments, post, data)
+			} else {
+				act.skip(fmt.Sprintf("comment not selected by %s", pf.commentRatioName))
+			}
+
+			if doLike {
+				act.Like = true
+			} else {
+				act.skip(fmt.Sprintf("like not selected by %s", pf.likeRatioName))
+			}
+		}
+
+		if !doSeen {
+			data.see(f.name, post.postID)
+			data.processed(f.name, post.postID)
+		}
+
+		// Actions that aren't due yet are sent in a later run, and so are the ones over budget.
+		now, due := time.Now(), time.Time{}
+		if act.Like {
+			due = cfg.schedule.due(cfg.rand, post.date, now)
+		}
+		switch {
+		case due.After(now):
+			act.Scheduled = &due
+			data.queue(newPending(act, post.userID, now, due))
+		case !cfg.budget.fits(act):
+			act.Deferred = true
+			act.skip("over budget")
+			data.queue(newPending(act, post.userID, now, time.Time{}))
+		}
+
+		if err := cfg.send(act, newPending(act, post.userID, now, time.Time{}), data, inp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type data struct {
+	Version int       `json:"version"`
+	Group   seenPosts `json:"group"`
+	Company seenPosts `json:"company"`
+	// Recent are the comments recently made per user id.
+	Recent map[string][]*recentComment `json:"recent,omitempty"`
+	// Liked and Commented are when posts were liked and commented in the last 24 hours.
+	Liked     []time.Time `json:"liked,omitempty"`
+	Commented []time.Time `json:"commented,omitempty"`
+	// Pending are the actions deferred to a later run.
+	Pending []*pending `json:"pending,omitempty"`
+	// InFlight is the action being sent, it's saved before it's sent.
+	InFlight *pending `json:"inFlight,omitempty"`
+	// Unprocessed are the posts that were fetched but not processed yet.
+	Unprocessed []*savedPost `json:"unprocessed,omitempty"`
+}
+
+func (cfg *cfg) load(inp *Input) (*data, []*comment, error) {
+	email := strings.ToLower(inp.Email)
+	stateFile := fmt.Sprintf("%s.json", email)
+
+	// Read comments data, the first of the rule files or the legacy comments file found is used.
+	var rawComments []byte
+	var err error
+	for _, ext := range commentsExts {
+		rawComments, err = cfg.store.download(fmt.Sprintf("%s.comments.%s", email, ext))
+		if err == nil || !errors.Is(err, errNotFound) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't read comment data. %w", err)
+	}
+
+	comments, err := loadComments(rawComments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't load comments. %w", err)
+	}
+
+	// The timezone in the input takes precedence over the one in the rule file.
+	if cfg.loc != nil {
+		for _, comment := range comments {
+			comment.loc = cfg.loc
+		}
+	}
+
+	// Read the people file, it's optional.
+	for _, ext := range peopleExts {
+		rawPeople, err := cfg.store.download(fmt.Sprintf("%s.people.%s", email, ext))
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("couldn't read people data. %w", err)
+		}
+
+		if cfg.people, err = loadPeople(rawPeople); err != nil {
+			return nil, nil, fmt.Errorf("couldn't load people. %w", err)
+		}
+		break
+	}
+
+	// Read personal state data.
+	raw, version, err := cfg.store.downloadVersion(stateFile)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			if inp.MarkAsSeen {
+				return &data{}, comments, nil
+			}
+			return nil, comments, fmt.Errorf("first load needs to be with markAsSeen true. %w", err)
+		}
+		return nil, comments, fmt.Errorf("couldn't read state data. %w", err)
+	}
+
+	cfg.version = version
+
+	d := &data{}
+	if err := json.Unmarshal(raw, d); err != nil {
+		return nil, comments, fmt.Errorf("couldn't json unmarshal body of state data. %w", err)
+	}
+
+	// Older states are migrated when they are read, and saved as the current version.
+	if d.Version > stateVersion {
+		return nil, comments, fmt.Errorf("unsupported state version %d, expected at most %d", d.Version, stateVersion)
+	}
+	if d.Version < stateVersion {
+		fmt.Printf("migrating state of %s to version %d\n", email, stateVersion)
+	}
+
+	return d, comments, nil
+}
+
+func (cfg *cfg) save(inp *Input, data *data) error {
+	email := strings.ToLower(inp.Email)
+	data.prune(time.Now().Add(-cfg.commentWindow))
+	day := time.Now().Add(-24 * time.Hour)
+	data.Liked, data.Commented = after(data.Liked, day), after(data.Commented, day)
+	if cfg.seenHorizon > 0 {
+		data.Group.prune(time.Now().Add(-cfg.seenHorizon))
+		data.Company.prune(time.Now().Add(-cfg.seenHorizon))
+	}
+	data.Version = stateVersion
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("couldn't json marshal state data for %s. %w", email, err)
+	}
+
+	// The state is only saved if no other run saved it since it was loaded.
+	file := fmt.Sprintf("%s.json", strings.ToLower(email))
+	version, err := cfg.store.saveIf(file, raw, cfg.version)
+	if err != nil {
+		return fmt.Errorf("couldn't save state data for %s. %w", email, err)
+	}
+	cfg.version = version
+
+	return nil
+}
+
+// see adds the post to the seen posts of the feed.
+func (d *data) see(feed string, id string) {
+	switch feed {
+	case "group":
+		if d.Group == nil {
+			d.Group = seenPosts{}
+		}
+		d.Group[id] = time.Now()
+	case "company":
+		if d.Company == nil {
+			d.Company = seenPosts{}
+		}
+		d.Company[id] = time.Now()
+	}
+}
+
+// send sends the action. The state is saved with the action in flight before it's
+// sent and again with it done after, so it isn't sent again if the run fails.
+func (cfg *cfg) send(act *action, p *pending, data *data, inp *Input) error {
+	if act.Deferred || act.Scheduled != nil {
+		return cfg.act(act, inp)
+	}
+
+	cfg.budget.use(act)
+	if inp.DryRun || (!act.Like && len(act.Comments) == 0) {
+		return cfg.act(act, inp)
+	}
+
+	data.InFlight = p
+	if err := cfg.save(inp, data); err != nil {
+		return err
+	}
+
+	if err := cfg.act(act, inp); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	data.InFlight = nil
+	data.done(act, inp, now)
+	if act.Commented && p.Rule != "" && cfg.commentWindow > 0 {
+		data.remember(p.UserID, p.Rule, now)
+	}
+
+	return cfg.save(inp, data)
+}
+
+type request struct {
+	method      string
+	url         string
+	body        []byte
+	contentType string
+	accept      string
+	origin      string
+	referer     string
+}
+
+func newRequest(req *request) (*http.Request, error) {
+	r, err := http.NewRequest(req.method, req.url, bytes.NewReader(req.body))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create new http request for %s %s. %w", req.method, req.url, err)
+	}
+
+	r.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 11.2; rv:86.0) Gecko/20100101 Firefox/86.0")
+
+	if req.contentType != "" {
+		r.Header.Set("Content-Type", req.contentType)
+	}
+
+	if req.accept != "" {
+		r.Header.Set("Accept", req.accept)
+	}
+
+	if req.origin != "" {
+		r.Header.Set("Origin", req.origin)
+	}
+
+	if req.referer != "" {
+		r.Header.Set("Referer", req.referer)
+	}
+
+	return r, nil
+}
+
+func (cfg *cfg) url(path string) string {
+	return strings.TrimSuffix(cfg.baseURL, "/") + path
+}
+
+func (cfg *cfg) checkToken(body string) {
+	matches := tokenRegexp.FindStringSubmatch(body)
+	if len(matches) == 2 {
+		cfg.token = matches[1]
+	}
+}
+
+func (cfg *cfg) checkUserID(body string) {
+	matches := userIDRegexp.FindStringSubmatch(body)
+	if len(matches) == 2 {
+		cfg.userID = matches[1]
+	}
+}
+
+func (cfg *cfg) login(inp *Input) error {
+	if err := cfg.setAuthToken(); err != nil {
+		return err
+	}
+
+	return cfg.auth(inp.Email, cfg.password)
+}
+
+func (cfg *cfg) setAuthToken() error {
+	req, err := newRequest(&request{
+		method: "GET",
+		url:    cfg.url("/login"),
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("couldn't send http request to %s. %w", req.URL.String(), err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("couldn't read response body for %s. %w", req.URL.String(), err)
+	}
+	body := string(raw)
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("expected status code 200 from get session but got %d. %s", resp.StatusCode, body)
+	}
+
+	cfg.checkToken(body)
+
+	return nil
+}
+
+func (cfg *cfg) auth(username string, password string) error {
+	payload := url.Values{}
+	payload.Set("utf8", "✓")
+	payload.Set("authenticity_token", cfg.token)
+	payload.Set("email", username)
+	payload.Set("password", password)
+	payload.Set("commit", "Logga+in")
+
+	req, err := newRequest(&request{
+		method:      "POST",
+		url:         cfg.url("/sessions"),
+		body:        []byte(payload.Encode()),
+		contentType: "application/x-www-form-urlencoded",
+		origin:      cfg.baseURL,
+		referer:     cfg.url("/login"),
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("couldn't send http request to %s. %w", req.URL.String(), err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("couldn't read response body for %s. %w", req.URL.String(), err)
+	}
+	body := string(raw)
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("expected status code 200 from login but got %d. %s", resp.StatusCode, body)
+	}
+
+	cfg.checkToken(body)
+	cfg.checkUserID(body)
+
+	if strings.Contains(body, "Email eller lösenord är ogiltiga") {
+		return fmt.Errorf("wrong username or password")
+	}
+
+	return nil
+}
+
+type post struct {
+	group            bool
+	exercise         bool
+	date             time.Time
+	postID           string
+	userID           string
+	name             string
+	groupName        string
+	trainingDuration string
+	trainingType     string
+	text             string
+	sentiment        types.SentimentType
+	// resumed is if the post wasn't processed before the deadline of an earlier run.
+	resumed bool
+	// likeState is if the user likes the post, from the feed.
+	likeState likeState
+	// status is the comments the user has already made, it's fetched before acting if it's nil.
+	status *status
+}
+
+func (cfg *cfg) getFeed(prev seenPosts, feedType string, sort string, filter string, query string, offset string) ([]*post, error) {
+	qs := url.Values{}
+	qs.Set("utf8", "✓")
+	qs.Set("type", feedType)
+	qs.Set("sort", sort)
+	qs.Set("filter", filter)
+	qs.Set("query", query)
+	qs.Set("only_items", "true")
+	qs.Set("offset", offset)
+	req, err := newRequest(&request{
+		method:  "GET",
+		url:     cfg.url(fmt.Sprintf("/feed?%s", qs.Encode())),
+		referer: cfg.url("/"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't send http request to %s. %w", req.URL.String(), err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read response body for %s. %w", req.URL.String(), err)
+	}
+	body := string(raw)
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("expected status code 200 from get feeds but got %d. %s", resp.StatusCode, body)
+	}
+
+	cfg.checkToken(body)
+
+	page, errs, err := parseFeed(strings.NewReader(body), feedType, sort, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, err := range errs {
+		cfg.warn("skipping feed item. %s", err.Error())
+	}
+
+	ids := []*post{}
+	added := []string{}
+	done := false
+
+	for _, data := range page.posts {
+		// If at least one of the ids has been seen we can stop
+		// downloading new posts since we sort on created at.
+		if prev.has(data.postID) {
+			done = true
+		}
+
+		// If postID has been seen skip any further processing.
+		if seen(data.postID, added) {
+			continue
+		}
+
+		// Skip commenting and liking your own posts.
+		if data.userID == cfg.userID {
+			continue
+		}
+
+		data.sentiment = types.SentimentTypeNeutral
+
+		// Mark group feed as group post.
+		if feedType == "group" {
+			data.group = true
+		}
+
+		// Get comment text and what the user has already done.
+		st, err := cfg.getStatus(data.postID)
+		if err != nil {
+			cfg.warn("couldn't get status of post id %s. %s", data.postID, err.Error())
+		}
+		if st != nil {
+			data.text = st.text
+			data.status = st
+		}
+
+		ids = append(ids, data)
+		added = append(added, data.postID)
+	}
+
+	// If done is true we can just return and not process anymore posts.
+	if !done && page.more != "" {
+		new, err := cfg.getFeed(prev, feedType, sort, filter, query, page.more)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, new...)
+	}
+
+	return ids, nil
+}
+
+// getStatus returns the text of the post and the comments made by the user. The status
+// is returned with an error if the text is missing.
+func (cfg *cfg) getStatus(postID string) (*status, error) {
+	req, err := newRequest(&request{
+		method:  "GET",
+		url:     cfg.url(fmt.Sprintf("/statuses/%s?layout=false", postID)),
+		referer: cfg.url("/"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't send http request to %s. %w", req.URL.String(), err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read response body for %s. %w", req.URL.String(), err)
+	}
+	body := string(raw)
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("expected status code 200 from get status but got %d. %s", resp.StatusCode, body)
+	}
+
+	cfg.checkToken(body)
+
+	st, err := parseStatus(strings.NewReader(body), postID, cfg.userID)
+	if err != nil {
+		return nil, err
+	}
+
+	textMatches := textRegexp.FindStringSubmatch(body)
+	if len(textMatches) != 2 {
+		return st, fmt.Errorf("expected text matches to be 2 but got %d", len(textMatches))
+	}
+	st.text = textMatches[1]
+
+	return st, nil
+}
+
+func (cfg *cfg) like(id string) error {
+	payload := url.Values{}
+	payload.Set("like[status_id]", id)
+	payload.Set("link_css_id", fmt.Sprintf("like-status-%s", id))
+
+	req, err := newRequest(&request{
+		method:      "POST",
+		url:         cfg.url("/likes"),
+		body:        []byte(payload.Encode()),
+		contentType: "application/x-www-form-urlencoded; charset=UTF-8",
+		accept:      defAccept,
+		origin:      cfg.baseURL,
+		referer:     cfg.url("/"),
+	})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.Header.Set("X-CSRF-Token", cfg.token)
+
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("couldn't send http request to %s. %w", req.URL.String(), err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("couldn't read response body for %s. %w", req.URL.String(), err)
+	}
+	body := string(raw)
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("expected status code 200 from like but got %d. %s", resp.StatusCode, body)
+	}
+
+	cfg.checkToken(body)
+
+	return nil
+}
+
+func (cfg *cfg) comment(id string, comment string) error {
+	payload := url.Values{}
+	payload.Set("comment[body]", comment)
+	payload.Set("comment[status_id]", id)
+	payload.Set("comments_css_id", fmt.Sprintf("comments-list-%s", id))
+
+	req, err := newRequest(&request{
+		method:      "POST",
+		url:         cfg.url("/comments"),
+		body:        []byte(payload.Encode()),
+		contentType: "application/x-www-form-urlencoded; charset=UTF-8",
+		accept:      defAccept,
+		origin:      cfg.baseURL,
+		referer:     cfg.url("/"),
+	})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.Header.Set("X-CSRF-Token", cfg.token)
+
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("couldn't send http request to %s. %w", req.URL.String(), err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("couldn't read response body for %s. %w", req.URL.String(), err)
+	}
+	body := string(raw)
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("expected status code 200 from comment but got %d. %s", resp.StatusCode, body)
+	}
+
+	cfg.checkToken(body)
+
+	return nil
+}
+
+// deadline returns if there is less than 30 seconds left of the run.
+func (cfg *cfg) deadline() bool {
+	dl, ok := cfg.ctx.Deadline()
+	return ok && time.Now().Add(time.Duration(30)*time.Second).After(dl)
+}
+
+func seen(id string, slice []string) bool {
+	for _, ssid := range slice {
+		if id == ssid {
+			return true
+		}
+	}
+	return false
+}
+
+func doAction(rng *rand.Rand, id string, prev seenPosts, likeRatio float64, commentRatio float64) (bool, bool, bool) {
+	doSeen := prev.has(id)
+	if doSeen {
+		return false, false, doSeen
+	}
+
+	like := rng.Float64() < likeRatio
+	comment := rng.Float64() < commentRatio
+
+	return like, comment, doSeen
+}
+
+// choose picks a matching comment rule for the post and renders its comments.
+func (cfg *cfg) choose(act *action, comments []*comment, post *post, data *data) {
+	now := time.Now()
+	rule := random(cfg.rand, comments, post, now, data.recent(post.userID, now.Add(-cfg.commentWindow)))
+	if rule == nil {
+		act.skip("no comment rule matched")
+		return
+	}
+	act.rule = rule
+
+	act.Rule = &matchedRule{Name: rule.name, Line: rule.line, Expression: rule.raw, Weight: rule.weight}
+	for _, tmpl := range rule.templates {
+		msg, err := render(tmpl, post, rule.loc, cfg.rand)
+		if err != nil {
+			cfg.warn("couldn't render comment for post id %s with rule %s. %s", post.postID, rule.name, err.Error())
+			act.Comments = nil
+			act.skip("couldn't render comment")
+			return
+		}
+		act.Comments = append(act.Comments, msg)
+	}
+}
+
+// random picks one of the matching comments, avoiding the ones in recent. The strategy
+// of the rule file decides if the highest weight wins or if weights are probabilities.
+func random(rng *rand.Rand, comments []*comment, post *post, now time.Time, recent []*recentComment) *comment {
+	valid := validComments(comments, post, now)
+	if len(valid) == 0 {
+		fmt.Printf("no comments matched for post: '%+v'\n", *post)
+		return nil
+	}
+
+	if valid[0].strategy == strategyWeighted {
+		return weighted(rng, leastRecent(valid, recent))
+	}
+
+	// Rules with negative weights are never picked with the priority strategy.
+	valid = highestWeight(valid)
+	if len(valid) == 0 {
+		return nil
+	}
+
+	valid = leastRecent(valid, recent)
+	return valid[rng.Intn(len(valid))]
+}
+
+// highestWeight returns the comments with the highest weight.
+func highestWeight(comments []*comment) []*comment {
+	highest := []*comment{}
+	for _, comnt := range comments {
+		// Either reset or add depending on weight.
+		// If weight is lower than current, or negative, don't add.
+		switch {
+		case comnt.weight < 0:
+			continue
+		case len(highest) == 0 || comnt.weight > highest[0].weight:
+			highest = []*comment{comnt}
+		case comnt.weight == highest[0].weight:
+			highest = append(highest, comnt)
+		}
+	}
+
+	return highest
+}
+
+// weighted picks one of the comments with a probability proportional to its weight.
+// Comments without a weight have the weight 1.
+func weighted(rng *rand.Rand, comments []*comment) *comment {
+	total := 0
+	for _, comnt := range comments {
+		total += comnt.probability()
+	}
+
+	n := rng.Intn(total)
+	for _, comnt := range comments {
+		if n < comnt.probability() {
+			return comnt
+		}
+		n -= comnt.probability()
+	}
+
+	return comments[len(comments)-1]
+}
+
+// validComments returns the comments that match the post.
+func validComments(comments []*comment, post *post, now time.Time) []*comment {
+	valid := []*comment{}
+
+	for _, comnt := range comments {
+		// If expression is empty and it's post or group don't add.
+		if comnt.match == nil && (!post.exercise || post.group) {
+			continue
+		}
+
+		// If sentiment is negative or mixed and sentiment isn't marked as negative simply don't add it.
+		switch post.sentiment {
+		case types.SentimentTypeMixed:
+			if comnt.sentiment != "neg" {
+				continue
+			}
+		case types.SentimentTypeNegative:
+			if comnt.sentiment != "neg" {
+				continue
+			}
+		}
+
+		// Group and none exercise posts only match rules that explicitly are for them.
+		if (!post.exercise || post.group) && !comnt.match.has(postType(post)) {
+			continue
+		}
+
+		// Without a timezone dates are compared in UTC.
+		loc := time.UTC
+		if comnt.loc != nil {
+			loc = comnt.loc
+		}
+
+		if comnt.match == nil || comnt.match.eval(&env{post: post, loc: loc, now: now}) {
+			valid = append(valid, comnt)
+		}
+	}
+
+	return valid
+}
+
+// postType returns a func that is true for the "type == x" expression that group and
+// none exercise posts can be matched with.
+func postType(post *post) func(e *expression) bool {
+	value := "post"
+	switch {
+	case post.group && post.exercise:
+		value = "group"
+	case post.group:
+		value = "group-post"
+	}
+
+	return func(e *expression) bool {
+		return e.is("type", "==", value)
+	}
+}
+
+func (cfg *cfg) sentiment(post *post) error {
+	if post.text == "" {
+		return nil
+	}
+
+	sentiment, err := cfg.analyzer.sentiment(post.text)
+	if err != nil {
+		return err
+	}
+
+	post.sentiment = sentiment
+	return nil
+}
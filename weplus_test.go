@@ -0,0 +1,803 @@
+package weplus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/comprehend/types"
+)
+
+// fixtureTransport answers requests to weplusapp.com with the saved pages in testdata.
+type fixtureTransport struct {
+	requests []string
+}
+
+func (ft *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ft.requests = append(ft.requests, fmt.Sprintf("%s %s", req.Method, req.URL.RequestURI()))
+
+	file := ""
+	switch {
+	case req.URL.Path == "/login":
+		file = "login.html"
+	case req.URL.Path == "/sessions":
+		file = "sessions.html"
+		if err := req.ParseForm(); err == nil && req.PostForm.Get("password") != "secret" {
+			file = "sessions_invalid.html"
+		}
+	case req.URL.Path == "/feed":
+		qs := req.URL.Query()
+		file = fmt.Sprintf("feed_%s_%s.html", qs.Get("type"), qs.Get("offset"))
+	case strings.HasPrefix(req.URL.Path, "/statuses/"):
+		file = fmt.Sprintf("status_%s.html", strings.TrimPrefix(req.URL.Path, "/statuses/"))
+	}
+
+	raw, err := os.ReadFile(filepath.Join("testdata", file))
+	if file == "" || err != nil {
+		return &http.Response{
+			StatusCode: 404,
+			Body:       io.NopCloser(strings.NewReader("not found")),
+			Request:    req,
+		}, nil
+	}
+
+	return &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(strings.NewReader(string(raw))),
+		Request:    req,
+	}, nil
+}
+
+func newFixtureCfg(t *testing.T) (*cfg, *fixtureTransport) {
+	t.Helper()
+
+	ft := &fixtureTransport{}
+	return &cfg{
+		ctx:     context.Background(),
+		client:  &http.Client{Transport: ft},
+		userID:  "10001",
+		baseURL: defBaseURL,
+		rand:    rand.New(rand.NewSource(1)),
+	}, ft
+}
+
+func date(t *testing.T, str string) time.Time {
+	t.Helper()
+
+	d, err := time.Parse(dateFormat, str)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func comparePosts(t *testing.T, got []*post, want []*post) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %d posts but got %d", len(want), len(got))
+	}
+
+	for i := range want {
+		g, w := *got[i], *want[i]
+		if !g.date.Equal(w.date) {
+			t.Errorf("post %d: expected date %s but got %s", i, w.date, g.date)
+		}
+		// The status is checked by TestGetStatus.
+		g.date, w.date = time.Time{}, time.Time{}
+		g.status, w.status = nil, nil
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("post %d:\n got: %+v\nwant: %+v", i, g, w)
+		}
+	}
+}
+
+func TestGetFeed(t *testing.T) {
+	cases := []struct {
+		name     string
+		prev     seenPosts
+		feedType string
+		filter   string
+		want     []*post
+		requests []string
+	}{
+		{
+			name:     "group feed follows feed-more-item",
+			feedType: "group",
+			filter:   "all",
+			want: []*post{
+				{
+					group: true, exercise: true, date: date(t, "Sat, 20 Mar 2021 07:15:00 +0100"),
+					postID: "2000003", userID: "10002", name: "Cecilia Carlsson", groupName: "@Save the Hawk Foundation", likeState: likeNotLiked,
+					trainingDuration: "45", trainingType: "Löpning", text: "Morning run in the rain!", sentiment: types.SentimentTypeNeutral,
+				},
+				{
+					group: true, date: date(t, "Sat, 20 Mar 2021 06:30:00 +0100"),
+					postID: "2000002", userID: "10003", name: "David Dahl", groupName: "@Save the Hawk Foundation", likeState: likeNotLiked,
+					text: "Who is joining the walk on Sunday?", sentiment: types.SentimentTypeNeutral,
+				},
+				{
+					group: true, exercise: true, date: date(t, "Fri, 19 Mar 2021 18:45:00 +0100"),
+					postID: "1999999", userID: "10004", name: "Frida Fors", groupName: "@Save the Hawk Foundation", likeState: likeNotLiked,
+					trainingDuration: "95", trainingType: "Cykling", text: "Long ride to the coast.\nLegs are done.", sentiment: types.SentimentTypeNeutral,
+				},
+			},
+			requests: []string{
+				"GET /feed?filter=all&offset=0&only_items=true&query=&sort=created-at&type=group&utf8=%E2%9C%93",
+				"GET /statuses/2000003?layout=false",
+				"GET /statuses/2000002?layout=false",
+				"GET /feed?filter=all&offset=12&only_items=true&query=&sort=created-at&type=group&utf8=%E2%9C%93",
+				"GET /statuses/1999999?layout=false",
+			},
+		},
+		{
+			name:     "group feed stops paging at seen post",
+			prev:     seenPosts{"2000002": time.Now()},
+			feedType: "group",
+			filter:   "all",
+			want: []*post{
+				{
+					group: true, exercise: true, date: date(t, "Sat, 20 Mar 2021 07:15:00 +0100"),
+					postID: "2000003", userID: "10002", name: "Cecilia Carlsson", groupName: "@Save the Hawk Foundation", likeState: likeNotLiked,
+					trainingDuration: "45", trainingType: "Löpning", text: "Morning run in the rain!", sentiment: types.SentimentTypeNeutral,
+				},
+				{
+					group: true, date: date(t, "Sat, 20 Mar 2021 06:30:00 +0100"),
+					postID: "2000002", userID: "10003", name: "David Dahl", groupName: "@Save the Hawk Foundation", likeState: likeNotLiked,
+					text: "Who is joining the walk on Sunday?", sentiment: types.SentimentTypeNeutral,
+				},
+			},
+			requests: []string{
+				"GET /feed?filter=all&offset=0&only_items=true&query=&sort=created-at&type=group&utf8=%E2%9C%93",
+				"GET /statuses/2000003?layout=false",
+				"GET /statuses/2000002?layout=false",
+			},
+		},
+		{
+			name:     "company feed skips broken items and missing texts",
+			feedType: "company",
+			filter:   "image-or-video",
+			want: []*post{
+				{
+					exercise: true, date: date(t, "Sat, 20 Mar 2021 08:05:00 +0100"),
+					postID: "3000002", userID: "10005", name: "Gustav Grön", groupName: "@Competitors", likeState: likeNotLiked,
+					trainingDuration: "120", trainingType: "Promenad", text: "Slow but steady", sentiment: types.SentimentTypeNeutral,
+				},
+				{
+					date:   date(t, "Sat, 20 Mar 2021 07:50:00 +0100"),
+					postID: "3000001", userID: "10002", name: "Cecilia Carlsson", groupName: "@Save the Hawk Foundation", likeState: likeNotLiked,
+					text: "Terrible weather, knee hurts again.", sentiment: types.SentimentTypeNeutral,
+				},
+				{
+					exercise: true, date: date(t, "Fri, 19 Mar 2021 21:10:00 +0100"),
+					postID: "2999999", userID: "10006", name: "Hanna Holm & Co", groupName: "@Competitors", likeState: likeNotLiked,
+					trainingDuration: "20", trainingType: "Yoga", sentiment: types.SentimentTypeNeutral,
+				},
+			},
+			requests: []string{
+				"GET /feed?filter=image-or-video&offset=0&only_items=true&query=&sort=created-at&type=company&utf8=%E2%9C%93",
+				"GET /statuses/3000002?layout=false",
+				"GET /statuses/3000001?layout=false",
+				"GET /feed?filter=image-or-video&offset=12&only_items=true&query=&sort=created-at&type=company&utf8=%E2%9C%93",
+				"GET /statuses/2999999?layout=false",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg, ft := newFixtureCfg(t)
+
+			got, err := cfg.getFeed(c.prev, c.feedType, "created-at", c.filter, "", "0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			comparePosts(t, got, c.want)
+			if !reflect.DeepEqual(ft.requests, c.requests) {
+				t.Errorf("unexpected requests\n got: %q\nwant: %q", ft.requests, c.requests)
+			}
+		})
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	cases := []struct {
+		postID  string
+		want    *status
+		wantErr bool
+	}{
+		{postID: "2000003", want: &status{text: "Morning run in the rain!", comments: []string{}}},
+		{postID: "2000002", want: &status{text: "Who is joining the walk on Sunday?", comments: []string{}}},
+		{postID: "1999999", want: &status{text: "Long ride to the coast.\nLegs are done.", comments: []string{}}},
+		// The status is returned even if the text is missing.
+		{postID: "2999999", want: &status{comments: []string{}}, wantErr: true},
+		{postID: "1000000", wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.postID, func(t *testing.T) {
+			cfg, _ := newFixtureCfg(t)
+
+			got, err := cfg.getStatus(c.postID)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %t but got %v", c.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected %+v but got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	cases := []struct {
+		name     string
+		password string
+		userID   string
+		token    string
+		wantErr  bool
+	}{
+		{name: "valid", password: "secret", userID: "10001", token: "dG9rZW4tc2Vzc2lvbnM="},
+		{name: "invalid", password: "wrong", token: "dG9rZW4taW52YWxpZA==", wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg, ft := newFixtureCfg(t)
+			cfg.userID = ""
+			cfg.password = c.password
+
+			err := cfg.login(&Input{Email: "erik@example.com"})
+			if (err != nil) != c.wantErr {
+				t.Fatalf("expected error %t but got %v", c.wantErr, err)
+			}
+			if cfg.userID != c.userID {
+				t.Errorf("expected user id %q but got %q", c.userID, cfg.userID)
+			}
+			if cfg.token != c.token {
+				t.Errorf("expected token %q but got %q", c.token, cfg.token)
+			}
+			if want := []string{"GET /login", "POST /sessions"}; !reflect.DeepEqual(ft.requests, want) {
+				t.Errorf("unexpected requests %q", ft.requests)
+			}
+		})
+	}
+}
+
+func TestCheckToken(t *testing.T) {
+	cases := []struct {
+		file string
+		want string
+	}{
+		{file: "login.html", want: "dG9rZW4tbG9naW4="},
+		{file: "feed_group_0.html", want: "dG9rZW4tZmVlZC1ncm91cC0w"},
+		{file: "feed_company_12.html", want: "dG9rZW4tZmVlZC1jb21wYW55LTEy"},
+		{file: "status_2999999.html", want: "dG9rZW4tc3RhdHVzLTI5OTk5OTk="},
+	}
+
+	for _, c := range cases {
+		t.Run(c.file, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join("testdata", c.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := &cfg{token: "previous"}
+			cfg.checkToken(string(raw))
+			if cfg.token != c.want {
+				t.Errorf("expected %q but got %q", c.want, cfg.token)
+			}
+		})
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	fw := newFakeWeplus(t)
+	fw.add("group",
+		&fakePost{ID: "2000001", UserID: "10002", Name: "Cecilia Carlsson", Group: "@Hawks", Duration: 30, Kind: "Yoga", Date: date(t, "Sat, 20 Mar 2021 06:00:00 +0100"), Text: "Stretching"},
+		&fakePost{ID: "2000002", UserID: "10003", Name: "David Dahl", Group: "@Hawks", Duration: 45, Kind: "Löpning", Date: date(t, "Sat, 20 Mar 2021 07:00:00 +0100"), Text: "Rain run"},
+		&fakePost{ID: "2000003", UserID: "10001", Name: "Erik Ek", Group: "@Hawks", Duration: 20, Kind: "Yoga", Date: date(t, "Sat, 20 Mar 2021 07:30:00 +0100")},
+		&fakePost{ID: "2000004", UserID: "10004", Name: "Frida Fors", Group: "@Hawks", Date: date(t, "Sat, 20 Mar 2021 08:00:00 +0100"), Text: "Who is in for Sunday?"},
+		&fakePost{ID: "2000005", UserID: "10005", Name: "Gustav Grön", Group: "@Hawks", Duration: 90, Kind: "Cykling", Date: date(t, "Sat, 20 Mar 2021 09:00:00 +0100"), Text: "Long ride"},
+	)
+	fw.add("company",
+		&fakePost{ID: "3000001", UserID: "10006", Name: "Hanna Holm", Group: "@Competitors", Duration: 60, Kind: "Promenad", Date: date(t, "Sat, 20 Mar 2021 08:30:00 +0100")},
+		&fakePost{ID: "3000002", UserID: "10007", Name: "Ida Ivarsson", Group: "@Competitors", Date: date(t, "Sat, 20 Mar 2021 09:30:00 +0100")},
+	)
+
+	comments, err := loadComments([]byte("| type == group | {{Duration}} minutes of {{Type}}!\n| type == group-post | See you there\n|| Nice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	likeRatio, commentRatio, groupRatio := 1.0, 0.0, 1.0
+	inp := &Input{Email: fw.email, LikeRatio: &likeRatio, CommentRatio: &commentRatio, GroupLikeRatio: &groupRatio, GroupCommentRatio: &groupRatio}
+	state := &data{Group: seenPosts{"2000001": time.Now()}, Company: seenPosts{}}
+
+	run := func() {
+		cfg := fw.cfg(t)
+		if err := cfg.login(inp); err != nil {
+			t.Fatal(err)
+		}
+
+		groupPosts, err := cfg.getFeed(state.Group, "group", "created-at", "all", "", "0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		companyPosts, err := cfg.getFeed(state.Company, "company", "created-at", "image-or-video", "", "0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := cfg.processGroupFeeds(groupPosts, state, comments, inp); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.processCompanyFeeds(companyPosts, state, comments, inp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run()
+
+	if want := []string{"2000005", "2000004", "2000002", "3000002", "3000001"}; !reflect.DeepEqual(fw.liked(), want) {
+		t.Errorf("expected likes %q but got %q", want, fw.liked())
+	}
+
+	want := []*fakeComment{
+		{postID: "2000005", body: "90 minutes of Cykling!"},
+		{postID: "2000004", body: "See you there"},
+		{postID: "2000002", body: "45 minutes of Löpning!"},
+	}
+	if !reflect.DeepEqual(fw.commented(), want) {
+		t.Errorf("unexpected comments %+v", fw.commented())
+	}
+
+	// A second run must not act on anything that was already seen.
+	run()
+
+	if len(fw.liked()) != 5 || len(fw.commented()) != 3 {
+		t.Errorf("expected no new actions but got likes %q and %d comments", fw.liked(), len(fw.commented()))
+	}
+}
+
+func TestRun(t *testing.T) {
+	fw := newFakeWeplus(t)
+	fw.add("group",
+		&fakePost{ID: "2000001", UserID: "10002", Name: "Cecilia Carlsson", Group: "@Hawks", Duration: 30, Kind: "Yoga", Date: date(t, "Sat, 20 Mar 2021 06:00:00 +0100")},
+	)
+	fw.add("company",
+		&fakePost{ID: "3000001", UserID: "10006", Name: "Hanna Holm", Group: "@Competitors", Duration: 60, Kind: "Promenad", Date: date(t, "Sat, 20 Mar 2021 08:30:00 +0100"), Text: "Knee hurts"},
+	)
+
+	store := newMemStore()
+	store.save("erik@example.com.comments.txt", []byte("| type == group | Go {{Name}}!\n|| {{Duration}} minutes, nice\n| sentiment == neg && type == löpning | Get well soon"))
+
+	newCfg := func() *cfg {
+		cfg := fw.newCfg(t, store)
+		cfg.analyzer = memAnalyzer{"Knee hurts": types.SentimentTypeNegative}
+		return cfg
+	}
+
+	likeRatio, commentRatio := 1.0, 1.0
+	inp := &Input{Email: "Erik@example.com", LikeRatio: &likeRatio, CommentRatio: &commentRatio}
+
+	// The first run must mark everything as seen.
+	if _, err := newCfg().run(inp); err == nil || !strings.Contains(err.Error(), "markAsSeen") {
+		t.Fatalf("expected first run without markAsSeen to fail but got %v", err)
+	}
+
+	inp.MarkAsSeen = true
+	if _, err := newCfg().run(inp); err != nil {
+		t.Fatal(err)
+	}
+	if len(fw.liked()) != 0 || len(fw.commented()) != 0 {
+		t.Fatalf("expected markAsSeen run to not act but got likes %q", fw.liked())
+	}
+
+	fw.add("group",
+		&fakePost{ID: "2000002", UserID: "10003", Name: "David Dahl", Group: "@Hawks", Duration: 45, Kind: "Löpning", Date: date(t, "Sat, 20 Mar 2021 07:00:00 +0100")},
+	)
+	fw.add("company",
+		&fakePost{ID: "3000002", UserID: "10007", Name: "Ida Ivarsson", Group: "@Competitors", Duration: 25, Kind: "Yoga", Date: date(t, "Sat, 20 Mar 2021 09:30:00 +0100")},
+		&fakePost{ID: "3000003", UserID: "10008", Name: "Johan Jansson", Group: "@Competitors", Duration: 50, Kind: "Löpning", Date: date(t, "Sat, 20 Mar 2021 10:30:00 +0100"), Text: "Knee hurts"},
+	)
+
+	inp.MarkAsSeen = false
+	report, err := newCfg().run(inp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (counts{Posts: 5, Liked: 3, Commented: 3, Skipped: 2}); report.Counts != want {
+		t.Errorf("expected counts %+v but got %+v", want, report.Counts)
+	}
+
+	if want := []string{"2000002", "3000003", "3000002"}; !reflect.DeepEqual(fw.liked(), want) {
+		t.Errorf("expected likes %q but got %q", want, fw.liked())
+	}
+	want := []*fakeComment{
+		{postID: "2000002", body: "Go David Dahl!"},
+		{postID: "3000003", body: "Get well soon"},
+		{postID: "3000002", body: "25 minutes, nice"},
+	}
+	if !reflect.DeepEqual(fw.commented(), want) {
+		t.Errorf("unexpected comments %+v", fw.commented())
+	}
+
+	raw, err := store.download("erik@example.com.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &data{}
+	if err := json.Unmarshal(raw, state); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2000001", "2000002"}; !reflect.DeepEqual(seenIDs(state.Group), want) {
+		t.Errorf("expected group state %q but got %q", want, seenIDs(state.Group))
+	}
+	if want := []string{"3000001", "3000002", "3000003"}; !reflect.DeepEqual(seenIDs(state.Company), want) {
+		t.Errorf("expected company state %q but got %q", want, seenIDs(state.Company))
+	}
+
+	// The comments are remembered per user so they aren't repeated.
+	recent := map[string]string{}
+	for userID, comments := range state.Recent {
+		for _, c := range comments {
+			recent[userID] = c.Text
+		}
+	}
+	if want := map[string]string{"10003": "Go {{Name}}!", "10008": "Get well soon", "10007": "{{Duration}} minutes, nice"}; !reflect.DeepEqual(recent, want) {
+		t.Errorf("expected recent comments %q but got %q", want, recent)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	fw := newFakeWeplus(t)
+	fw.add("group",
+		&fakePost{ID: "2000001", UserID: "10002", Name: "Cecilia Carlsson", Group: "@Hawks", Duration: 30, Kind: "Yoga", Date: date(t, "Sat, 20 Mar 2021 06:00:00 +0100")},
+		&fakePost{ID: "2000002", UserID: "10003", Name: "David Dahl", Group: "@Hawks", Date: date(t, "Sat, 20 Mar 2021 07:00:00 +0100")},
+	)
+	fw.add("company",
+		&fakePost{ID: "3000001", UserID: "10006", Name: "Hanna Holm", Group: "@Competitors", Duration: 60, Kind: "Promenad", Date: date(t, "Sat, 20 Mar 2021 08:30:00 +0100")},
+	)
+
+	store := newMemStore()
+	store.save("erik@example.com.comments.txt", []byte("| type == group | Go {{Name}}!\n100 | duration >= 60 | {{Duration}} minutes | Wow"))
+	state := []byte(`{"group":["2000001"],"company":[]}`)
+	store.save("erik@example.com.json", state)
+
+	likeRatio, commentRatio := 1.0, 1.0
+	got := fw.run(t, store, &Input{Email: fw.email, LikeRatio: &likeRatio, CommentRatio: &commentRatio, DryRun: true})
+
+	if len(fw.liked()) != 0 || len(fw.commented()) != 0 {
+		t.Errorf("expected dry run to not act but got likes %q and %d comments", fw.liked(), len(fw.commented()))
+	}
+	if raw, _ := store.download("erik@example.com.json"); string(raw) != string(state) {
+		t.Errorf("expected dry run to leave state as is but got %s", raw)
+	}
+
+	want := []*action{
+		{PostID: "2000002", Feed: "group", Name: "David Dahl", Like: true, Reasons: []string{"no comment rule matched"}},
+		{PostID: "2000001", Feed: "group", Name: "Cecilia Carlsson", Skipped: true, Reasons: []string{"already seen"}},
+		{
+			PostID: "3000001", Feed: "company", Name: "Hanna Holm", Sentiment: "NEUTRAL", Like: true,
+			Rule: &matchedRule{Name: "line 2", Line: 2, Expression: "duration >= 60", Weight: 100}, Comments: []string{"60 minutes", "Wow"},
+		},
+	}
+	for _, act := range got.Actions {
+		act.rule, act.likeState, act.status = nil, likeUnknown, nil
+	}
+	if !reflect.DeepEqual(got.Actions, want) {
+		raw, _ := json.Marshal(got.Actions)
+		t.Errorf("unexpected actions %s", raw)
+	}
+	if want := (counts{Posts: 3, Skipped: 1}); got.Counts != want {
+		t.Errorf("expected counts %+v but got %+v", want, got.Counts)
+	}
+	if !got.DryRun || got.Aborted {
+		t.Errorf("expected dry run report but got %+v", got)
+	}
+}
+
+func TestRunTimezone(t *testing.T) {
+	fw := newFakeWeplus(t)
+	fw.add("company",
+		// Summer time started in Stockholm at 02:00 the same day, so this is 07:30 there.
+		&fakePost{ID: "3000001", UserID: "10006", Name: "Hanna Holm", Group: "@Competitors", Duration: 60, Kind: "Promenad", Date: date(t, "Sun, 28 Mar 2021 05:30:00 +0000")},
+	)
+
+	store := newMemStore()
+	store.save("erik@example.com.comments.yaml", []byte(`version: 1
+timezone: Europe/Stockholm
+rules:
+  - name: early
+    weight: 100
+    match: time < 07:00
+    comments: Early {{Time}}
+  - name: any
+    comments: Walking at {{Time}}
+`))
+	store.save("erik@example.com.json", []byte(`{"group":[],"company":[]}`))
+
+	cases := []struct {
+		timezone string
+		want     string
+	}{
+		{timezone: "", want: "Walking at 07:30"},
+		{timezone: "America/New_York", want: "Early 01:30"},
+		{timezone: "UTC", want: "Early 05:30"},
+	}
+
+	for _, c := range cases {
+		likeRatio, commentRatio := 1.0, 1.0
+		report := fw.run(t, store, &Input{Email: fw.email, LikeRatio: &likeRatio, CommentRatio: &commentRatio, DryRun: true, Timezone: c.timezone})
+
+		if got := report.Actions[0].Comments; len(got) != 1 || got[0] != c.want {
+			t.Errorf("expected comment %q in timezone %q but got %q", c.want, c.timezone, got)
+		}
+	}
+
+	if _, err := fw.newCfg(t, store).run(&Input{Email: fw.email, Timezone: "Europe/Gothenburg"}); err == nil || !strings.Contains(err.Error(), "couldn't load timezone Europe/Gothenburg") {
+		t.Errorf("expected unknown timezone to fail but got %v", err)
+	}
+}
+
+func TestRandomStrategy(t *testing.T) {
+	rules := `version: 1
+strategy: %s
+rules:
+  - name: often
+    weight: 3
+    comments: Often
+  - name: sometimes
+    comments: Sometimes
+  - name: never
+    weight: 5
+    match: type == yoga
+    comments: Never
+`
+	run := &post{trainingType: "Löpning", trainingDuration: "30", exercise: true}
+
+	cases := []struct {
+		strategy string
+		want     map[string]float64
+	}{
+		{strategy: "priority", want: map[string]float64{"often": 1}},
+		{strategy: "weighted", want: map[string]float64{"often": 0.75, "sometimes": 0.25}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.strategy, func(t *testing.T) {
+			comments, err := loadComments([]byte(fmt.Sprintf(rules, c.strategy)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rng := rand.New(rand.NewSource(1))
+			got := map[string]int{}
+			for i := 0; i < 10000; i++ {
+				got[random(rng, comments, run, time.Now(), nil).name]++
+			}
+
+			for name, want := range c.want {
+				if share := float64(got[name]) / 10000; share < want-0.02 || share > want+0.02 {
+					t.Errorf("expected %s to be chosen %.2f of the times but got %.2f", name, want, share)
+				}
+			}
+			if len(got) != len(c.want) {
+				t.Errorf("expected only %v to be chosen but got %v", c.want, got)
+			}
+		})
+	}
+
+	// The same seed gives the same choices.
+	comments, err := loadComments([]byte(fmt.Sprintf(rules, "weighted")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := rand.New(rand.NewSource(42)), rand.New(rand.NewSource(42))
+	for i := 0; i < 100; i++ {
+		if x, y := random(a, comments, run, time.Now(), nil), random(b, comments, run, time.Now(), nil); x != y {
+			t.Fatalf("expected the same choice with the same seed but got %s and %s", x.name, y.name)
+		}
+	}
+}
+
+func TestRandomNegativeWeight(t *testing.T) {
+	comments, err := loadComments([]byte("-5 | duration > 10 | hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &post{trainingType: "Löpning", trainingDuration: "30", exercise: true}
+	if got := random(rand.New(rand.NewSource(1)), comments, p, time.Now(), nil); got != nil {
+		t.Errorf("expected no comment but got %s", got.name)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	fw := newFakeWeplus(t)
+	for i := 0; i < 8; i++ {
+		fw.add("company", &fakePost{ID: fmt.Sprintf("300000%d", i), UserID: fmt.Sprintf("1000%d", i), Name: "Hanna Holm", Group: "@Competitors", Duration: 30 + i, Kind: "Promenad", Date: date(t, "Sat, 20 Mar 2021 08:30:00 +0100")})
+	}
+
+	store := fw.newStore("|| {{pick \"Nice\" \"Wow\" \"Great\"}}\n|| {{Duration}} minutes\n|| 👍")
+
+	run := func(seed *int64) *Report {
+		likeRatio, commentRatio := 0.5, 0.5
+		return fw.run(t, store, &Input{Email: fw.email, LikeRatio: &likeRatio, CommentRatio: &commentRatio, DryRun: true, Seed: seed})
+	}
+
+	// Runs without a seed get a random one that can be used to replay them.
+	first, second := run(nil), run(nil)
+	if first.Seed == second.Seed {
+		t.Errorf("expected runs to get different seeds but both got %d", first.Seed)
+	}
+
+	replay := run(&first.Seed)
+	if replay.Seed != first.Seed {
+		t.Errorf("expected seed %d in the report but got %d", first.Seed, replay.Seed)
+	}
+	for _, r := range []*Report{first, replay} {
+		for _, act := range r.Actions {
+			act.rule, act.likeState, act.status = nil, likeUnknown, nil
+		}
+	}
+	if !reflect.DeepEqual(first.Actions, replay.Actions) {
+		t.Errorf("expected the replay to make the same choices")
+	}
+}
+
+func TestGroupRatios(t *testing.T) {
+	fw := newFakeWeplus(t)
+	fw.add("group",
+		&fakePost{ID: "2000001", UserID: "10002", Name: "Cecilia Carlsson", Group: "@Hawks", Duration: 30, Kind: "Yoga", Date: date(t, "Sat, 20 Mar 2021 06:00:00 +0100")},
+		&fakePost{ID: "2000002", UserID: "10003", Name: "David Dahl", Group: "@Hawks", Duration: 45, Kind: "Löpning", Date: date(t, "Sat, 20 Mar 2021 07:00:00 +0100")},
+	)
+	fw.add("company",
+		&fakePost{ID: "3000001", UserID: "10006", Name: "Hanna Holm", Group: "@Competitors", Duration: 60, Kind: "Promenad", Date: date(t, "Sat, 20 Mar 2021 08:30:00 +0100")},
+	)
+
+	store := fw.newStore("| type == group | Go {{Name}}!\n|| Nice")
+
+	one, zero := 1.0, 0.0
+	cases := []struct {
+		name              string
+		groupLikeRatio    *float64
+		groupCommentRatio *float64
+		want              map[string]counts
+	}{
+		{name: "defaults", want: map[string]counts{"group": {Posts: 2, Liked: 2, Commented: 2}, "company": {Posts: 1, Liked: 1, Commented: 1}}},
+		{name: "only likes", groupLikeRatio: &one, groupCommentRatio: &zero, want: map[string]counts{"group": {Posts: 2, Liked: 2}, "company": {Posts: 1, Liked: 1, Commented: 1}}},
+		{name: "nothing", groupLikeRatio: &zero, groupCommentRatio: &zero, want: map[string]counts{"group": {Posts: 2, Skipped: 2}, "company": {Posts: 1, Liked: 1, Commented: 1}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			report := fw.run(t, store, &Input{Email: fw.email, LikeRatio: &one, CommentRatio: &one, GroupLikeRatio: c.groupLikeRatio, GroupCommentRatio: c.groupCommentRatio, DryRun: true})
+
+			// Count what would have been done per feed.
+			got := map[string]counts{}
+			for _, act := range report.Actions {
+				cnt := got[act.Feed]
+				cnt.Posts++
+				switch {
+				case act.Skipped:
+					cnt.Skipped++
+					if act.Feed == "group" && !reflect.DeepEqual(act.Reasons, []string{"comment not selected by groupCommentRatio", "like not selected by groupLikeRatio"}) {
+						t.Errorf("unexpected reasons %q", act.Reasons)
+					}
+				default:
+					if act.Like {
+						cnt.Liked++
+					}
+					if len(act.Comments) > 0 {
+						cnt.Commented++
+					}
+				}
+				got[act.Feed] = cnt
+			}
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected %+v but got %+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestRunAlreadyDone(t *testing.T) {
+	fw := newFakeWeplus(t)
+	fw.add("group",
+		&fakePost{ID: "2000001", UserID: "10002", Name: "Cecilia Carlsson", Group: "@Hawks", Duration: 30, Kind: "Yoga", Date: date(t, "Sat, 20 Mar 2021 06:00:00 +0100")},
+		&fakePost{ID: "2000002", UserID: "10003", Name: "David Dahl", Group: "@Hawks", Duration: 45, Kind: "Löpning", Date: date(t, "Sat, 20 Mar 2021 07:00:00 +0100")},
+	)
+	// The posts were liked and commented in the browser, or by a run whose state was lost.
+	fw.likes = []string{"2000001", "2000002"}
+	fw.comments = []*fakeComment{{postID: "2000002", body: "Great run David"}}
+
+	store := fw.newStore("| type == group | Go {{first .Name}}!")
+
+	max := 10
+	report := fw.run(t, store, &Input{Email: fw.email, MaxDailyLikes: &max, MaxDailyComments: &max})
+
+	// Only what was actually sent is counted, and used from the daily budget.
+	if report.Counts.Liked != 0 || report.Counts.Commented != 1 || report.Counts.Skipped != 1 {
+		t.Errorf("expected 0 liked, 1 commented and 1 skipped but got %+v", report.Counts)
+	}
+	raw, _ := store.download("erik@example.com.json")
+	d := &data{}
+	if err := json.Unmarshal(raw, d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Liked) != 0 || len(d.Commented) != 1 {
+		t.Errorf("expected 0 likes and 1 comment in the daily budget but got %s", raw)
+	}
+
+	reasons := map[string][]string{}
+	for _, act := range report.Actions {
+		reasons[act.PostID] = act.Reasons
+	}
+	want := map[string][]string{
+		"2000002": {"already liked", "already commented"},
+		"2000001": {"already liked"},
+	}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("expected reasons %q but got %q", want, reasons)
+	}
+
+	if got := fw.liked(); !reflect.DeepEqual(got, []string{"2000001", "2000002"}) {
+		t.Errorf("expected the posts to still be liked but got %q", got)
+	}
+	if got := fw.commented(); len(got) != 2 || got[1].postID != "2000001" {
+		t.Errorf("expected only 2000001 to be commented but got %+v", got)
+	}
+}
+
+func TestRunRetryComments(t *testing.T) {
+	fw := newFakeWeplus(t)
+	fw.add("group",
+		&fakePost{ID: "2000003", UserID: "10004", Name: "Frida Fors", Group: "@Hawks", Duration: 95, Kind: "Cykling", Date: time.Now().Add(-time.Hour)},
+	)
+	// The run that decided the comments was stopped after the second one was made.
+	fw.comments = []*fakeComment{{postID: "2000003", body: "Go Frida!"}}
+
+	store := fw.newStore("| type == group | Nice")
+	now := time.Now().UTC().Format(time.RFC3339)
+	store.save("erik@example.com.json", []byte(fmt.Sprintf(`{"version":2,"group":{"2000003":%q},"company":{},"pending":[{"postId":"2000003","feed":"group","userId":"10004","name":"Frida Fors","comments":["Nice","Go Frida!"],"deferred":%q}]}`, now, now)))
+
+	report := fw.run(t, store, &Input{Email: fw.email})
+
+	// The retry only skips the comment that was already made.
+	if act := report.Actions[0]; !act.Resumed || !reflect.DeepEqual(act.Reasons, []string{`already commented "Go Frida!"`}) {
+		raw, _ := json.Marshal(report.Actions)
+		t.Fatalf("expected the retry to skip the comment already made but got %s", raw)
+	}
+	if got := fw.commented(); len(got) != 2 || got[1].body != "Nice" {
+		t.Errorf("expected Nice to be commented but got %+v", got)
+	}
+}
+
+func TestActLikeUnknown(t *testing.T) {
+	cfg, ft := newFixtureCfg(t)
+
+	// The like state of the post isn't known, so it isn't liked since that could unlike it.
+	act := &action{PostID: "2000003", Feed: "group", Like: true}
+	if err := cfg.act(act, &Input{Email: "erik@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if act.Liked || !reflect.DeepEqual(act.Reasons, []string{"like state unknown"}) {
+		t.Errorf("expected the like to be skipped but got %+v", act)
+	}
+	if want := []string{"GET /statuses/2000003?layout=false"}; !reflect.DeepEqual(ft.requests, want) {
+		t.Errorf("expected requests %q but got %q", want, ft.requests)
+	}
+}